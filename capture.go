@@ -0,0 +1,186 @@
+package csvencoding
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var extraType = reflect.TypeOf(map[string]string(nil))
+
+// Bind a field capturing the record rather than a column,
+// csv:",rest", csv:",extra", csv:",line" or csv:",raw"
+func (p *plan) capture(field reflect.StructField, tag tagOptions, index []int) error {
+	switch {
+	case tag.has("rest"):
+		if field.Type != stringsType {
+			return fmt.Errorf("Can't collect the rest of a record into %s, use []string", field.Type)
+		}
+		p.rest = index
+	case tag.has("raw"):
+		if field.Type != stringsType {
+			return fmt.Errorf("Can't keep the raw record in %s, use []string", field.Type)
+		}
+		p.raw = index
+	case tag.has("extra"):
+		if field.Type != extraType {
+			return fmt.Errorf("Can't collect extra columns into %s, use map[string]string", field.Type)
+		}
+		p.extra = index
+	case tag.has("line"):
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		default:
+			return fmt.Errorf("Can't set the line number of %s, use an int", field.Type)
+		}
+		p.line = index
+	}
+	return nil
+}
+
+// The header columns no field of the plan is bound to. A repeated
+// column is bound when any of its group is, groups holds the
+// columns sharing each name, nil without repeats
+func (p *plan) unboundColumns(header []string, groups [][]int) []int {
+	bound := make([]bool, len(header))
+	for _, b := range p.bindings {
+		for _, column := range append([]int{b.column}, b.collect...) {
+			if column >= len(bound) {
+				continue
+			}
+			bound[column] = true
+			if column < len(groups) {
+				for _, repeat := range groups[column] {
+					bound[repeat] = true
+				}
+			}
+		}
+	}
+	var unbound []int
+	for i := range header {
+		if !bound[i] {
+			unbound = append(unbound, i)
+		}
+	}
+	return unbound
+}
+
+// Fill the capturing fields of v, raw is the record as read
+// and r the record fit to the header
+func (dec *Decoder) captureRecord(p *plan, v reflect.Value, raw, r []string, line int) {
+	if p.rest != nil {
+		var rest []string
+		if len(r) > p.width {
+			rest = append(rest, r[p.width:]...)
+		}
+		fieldByIndex(v, p.rest).Set(reflect.ValueOf(rest))
+	}
+	if p.raw != nil {
+		fieldByIndex(v, p.raw).Set(reflect.ValueOf(append([]string(nil), raw...)))
+	}
+	if p.extra != nil {
+		var extra map[string]string
+		for _, column := range p.unbound {
+			if column >= len(r) {
+				continue
+			}
+			if extra == nil {
+				extra = make(map[string]string, len(p.unbound))
+			}
+			extra[dec.header[column]] = r[column]
+		}
+		fieldByIndex(v, p.extra).Set(reflect.ValueOf(extra))
+	}
+	if p.line != nil {
+		fieldByIndex(v, p.line).SetInt(int64(line))
+	}
+}
+
+// The index of the csv:",extra" field of t, its own
+// or one promoted from an embedded struct
+func extraField(t reflect.Type) []int {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if tag.skip(field) {
+			continue
+		}
+		if tag.has("extra") && field.Type == extraType {
+			return []int{i}
+		}
+		if tag.inline(field) {
+			if index := extraField(field.Type); index != nil {
+				return append([]int{i}, index...)
+			}
+		}
+	}
+	return nil
+}
+
+// The extra columns of v, nil if a nil embedded struct is in the way
+func extraColumns(v reflect.Value, index []int) map[string]string {
+	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v.Interface().(map[string]string)
+}
+
+// Fix the extra columns of a layout to the sorted keys of v, like
+// the columns of map rows. Values without any leave them open
+func (l *layout) fixExtra(v reflect.Value) {
+	if l.extra == nil || l.extraKeys != nil {
+		return
+	}
+	extra := extraColumns(v, l.extra)
+	if len(extra) == 0 {
+		return
+	}
+	for key := range extra {
+		l.extraKeys = append(l.extraKeys, key)
+	}
+	sort.Strings(l.extraKeys)
+}
+
+// The cells of the extra columns of v, those Columns
+// doesn't name are dropped
+func (l *layout) extraCells(v reflect.Value, empty string) ([]string, error) {
+	extra := extraColumns(v, l.extra)
+	for key := range extra {
+		if l.extraAt != nil {
+			break
+		}
+		i := sort.SearchStrings(l.extraKeys, key)
+		if i < len(l.extraKeys) && l.extraKeys[i] == key {
+			continue
+		}
+		if l.extraHeader {
+			return nil, fmt.Errorf("unknown extra column `%s`, the header was written without it, name it with Columns", key)
+		}
+		return nil, fmt.Errorf("unknown extra column `%s`, columns are fixed by the first record", key)
+	}
+	cells := make([]string, len(l.extraKeys))
+	for i, key := range l.extraKeys {
+		cell, ok := extra[key]
+		if !ok {
+			cell = empty
+		}
+		cells[i] = cell
+	}
+	return cells, nil
+}
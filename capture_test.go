@@ -0,0 +1,104 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capturing the record", func() {
+	type audit struct {
+		Name  string
+		Age   int
+		Extra map[string]string `csv:",extra"`
+		Line  int               `csv:",line"`
+		Raw   []string          `csv:",raw"`
+	}
+	input := "name,age,team,notes\nbob,30,red,\"two\nlines\"\nsue,40,blue,\n"
+
+	It("should decode unmapped columns, the line and the raw record", func() {
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var output []audit
+		for {
+			var a audit
+			err := decoder.Decode(&a)
+			if err == io.EOF {
+				break
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			output = append(output, a)
+		}
+		Ω(output).Should(Equal([]audit{
+			{"bob", 30, map[string]string{"team": "red", "notes": "two\nlines"}, 2, []string{"bob", "30", "red", "two\nlines"}},
+			{"sue", 40, map[string]string{"team": "blue", "notes": ""}, 4, []string{"sue", "40", "blue", ""}},
+		}))
+	})
+
+	It("should decode the line in parallel", func() {
+		decoder := csvencoding.NewParallelDecoder[audit](csvencoding.NewDecoder(reader(input)), 2)
+		var output audit
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output.Line).Should(Equal(4))
+		Ω(output.Extra).Should(HaveKeyWithValue("team", "blue"))
+	})
+
+	It("should write extra columns back out", func() {
+		decoder := csvencoding.NewDecoder(reader(input))
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.Dialect{})
+		for {
+			var a audit
+			if decoder.Decode(&a) != nil {
+				break
+			}
+			Ω(encoder.Encode(a)).Should(Succeed())
+		}
+		Ω(buffer.String()).Should(Equal("name,age,notes,team\nbob,30,\"two\nlines\",red\nsue,40,,blue\n"))
+
+		err := encoder.Encode(audit{Name: "kim", Extra: map[string]string{"office": "paris"}})
+		Ω(err).Should(MatchError("unknown extra column `office`, columns are fixed by the first record"))
+	})
+
+	It("should choose extra columns with Columns", func() {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.Dialect{})
+		encoder.Columns([]string{"name"})
+		Ω(encoder.Encode(audit{Name: "a", Extra: map[string]string{"x": "1"}})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("name\na\n"))
+
+		buffer.Reset()
+		encoder = csvencoding.NewWriterEncoder(buffer, csvencoding.Dialect{})
+		encoder.Columns([]string{"team=Team", "name", "office"})
+		Ω(encoder.Encode(audit{Name: "a", Extra: map[string]string{"team": "red", "x": "1"}})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("Team,name,office\nred,a,\n"))
+	})
+
+	It("should leave extra columns open until a value has some", func() {
+		encoder := csvencoding.NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+		header, err := encoder.Header(audit{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(header).Should(Equal([]string{"name", "age"}))
+		header, err = encoder.Header(audit{Extra: map[string]string{"team": "red"}})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(header).Should(Equal([]string{"name", "age", "team"}))
+
+		encoder = csvencoding.NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+		Ω(encoder.EncodeHeader(audit{})).Should(Succeed())
+		err = encoder.Encode(audit{Extra: map[string]string{"team": "red"}})
+		Ω(err).Should(MatchError("unknown extra column `team`, the header was written without it, name it with Columns"))
+	})
+
+	It("should only capture into the right types", func() {
+		type bad struct {
+			Extra map[string]int `csv:",extra"`
+		}
+		decoder := csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input)))
+		Ω(decoder.Decode(&bad{})).Should(MatchError("line 2: Can't collect extra columns into map[string]int, use map[string]string"))
+	})
+})
@@ -0,0 +1,282 @@
+package csvencoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A Charset is a character encoding csv files are transcoded
+// from and to, a nil *Charset is UTF-8
+type Charset struct {
+	Name string
+	// Runes of the bytes 0x80-0xFF of a single byte code page
+	high *[128]rune
+	// The bytes of high, for encoding
+	low map[rune]byte
+	// Byte order of UTF-16
+	order binary.ByteOrder
+}
+
+// NewCodePage describes a single byte code page, bytes below 0x80
+// are ASCII and high holds the runes of the bytes 0x80-0xFF
+func NewCodePage(name string, high [128]rune) *Charset {
+	c := &Charset{Name: name, high: &high, low: make(map[rune]byte, len(high))}
+	for i, r := range high {
+		if _, ok := c.low[r]; !ok {
+			c.low[r] = byte(0x80 + i)
+		}
+	}
+	return c
+}
+
+var (
+	UTF16LE = &Charset{Name: "UTF-16LE", order: binary.LittleEndian}
+	UTF16BE = &Charset{Name: "UTF-16BE", order: binary.BigEndian}
+	// Latin-1, every byte is the rune of the same value
+	ISO88591 = NewCodePage("ISO-8859-1", latin1High())
+	// Latin-1 with printable characters in place of most C1 controls,
+	// what Excel on Windows writes in western locales
+	Windows1252 = NewCodePage("Windows-1252", windows1252High())
+)
+
+func latin1High() [128]rune {
+	var high [128]rune
+	for i := range high {
+		high[i] = rune(0x80 + i)
+	}
+	return high
+}
+
+func windows1252High() [128]rune {
+	high := latin1High()
+	// 0x81, 0x8D, 0x8F, 0x90 and 0x9D are undefined, they keep their C1 rune
+	copy(high[:0x20], []rune{
+		0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+		0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	})
+	return high
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// NewCharsetReader returns r transcoded to UTF-8. A byte order mark
+// is dropped and decides the charset, UTF-8 or UTF-16, otherwise
+// r is read as c. Nothing is read from r until the first Read
+func NewCharsetReader(r io.Reader, c *Charset) io.Reader {
+	return &charsetReader{r: r, c: c}
+}
+
+// Sniffs the byte order mark on the first Read
+type charsetReader struct {
+	r io.Reader
+	c *Charset
+	// r transcoded, once the byte order mark is read
+	decoded io.Reader
+}
+
+func (cr *charsetReader) Read(p []byte) (int, error) {
+	if cr.decoded == nil {
+		cr.decoded = cr.start()
+	}
+	return cr.decoded.Read(p)
+}
+
+func (cr *charsetReader) start() io.Reader {
+	r, c := cr.r, cr.c
+	prefix := make([]byte, len(utf8BOM))
+	n, err := io.ReadFull(r, prefix)
+	prefix = prefix[:n]
+
+	switch {
+	case bytes.HasPrefix(prefix, utf8BOM):
+		prefix, c = prefix[len(utf8BOM):], nil
+	case bytes.HasPrefix(prefix, utf16LEBOM):
+		prefix, c = prefix[len(utf16LEBOM):], UTF16LE
+	case bytes.HasPrefix(prefix, utf16BEBOM):
+		prefix, c = prefix[len(utf16BEBOM):], UTF16BE
+	}
+
+	rest := r
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		rest = errReader{err}
+	}
+	r = io.MultiReader(bytes.NewReader(prefix), rest)
+	if c == nil {
+		return r
+	}
+	return &decodingReader{r: r, decode: c.decode, buffer: make([]byte, 4096)}
+}
+
+// NewCharsetWriter returns a writer that transcodes UTF-8 to c,
+// runes c can't represent fail the write. When bom is set the
+// byte order mark of UTF-8 or UTF-16 is written first
+func NewCharsetWriter(w io.Writer, c *Charset, bom bool) io.Writer {
+	ew := &encodingWriter{w: w}
+	if bom {
+		switch {
+		case c == nil:
+			ew.bom = utf8BOM
+		case c.order == binary.LittleEndian:
+			ew.bom = utf16LEBOM
+		case c.order == binary.BigEndian:
+			ew.bom = utf16BEBOM
+		}
+	}
+	if c != nil {
+		ew.encode = c.encode
+	}
+	return ew
+}
+
+// Decode src to UTF-8, returning how much of src was used,
+// incomplete characters are left for the next call unless atEOF
+func (c *Charset) decode(dst, src []byte, atEOF bool) ([]byte, int) {
+	if c.order == nil {
+		for _, b := range src {
+			r := rune(b)
+			if b >= 0x80 {
+				r = c.high[b-0x80]
+			}
+			dst = utf8.AppendRune(dst, r)
+		}
+		return dst, len(src)
+	}
+
+	i := 0
+	for ; i+1 < len(src); i += 2 {
+		r := rune(c.order.Uint16(src[i:]))
+		if utf16.IsSurrogate(r) {
+			if i+3 >= len(src) {
+				if !atEOF {
+					break
+				}
+				r = utf8.RuneError
+			} else if pair := utf16.DecodeRune(r, rune(c.order.Uint16(src[i+2:]))); pair != utf8.RuneError {
+				r = pair
+				i += 2
+			} else {
+				r = utf8.RuneError
+			}
+		}
+		dst = utf8.AppendRune(dst, r)
+	}
+	// A trailing odd byte
+	if atEOF && i < len(src) {
+		dst = utf8.AppendRune(dst, utf8.RuneError)
+		i = len(src)
+	}
+	return dst, i
+}
+
+// Encode UTF-8 src as c, returning how much of src was used,
+// an incomplete rune at the end is left for the next call
+func (c *Charset) encode(dst, src []byte) ([]byte, int, error) {
+	i := 0
+	for i < len(src) && utf8.FullRune(src[i:]) {
+		r, size := utf8.DecodeRune(src[i:])
+		if r == utf8.RuneError && size == 1 {
+			return dst, i, fmt.Errorf("Can't encode invalid UTF-8 as %s", c.Name)
+		}
+		switch {
+		case c.order != nil:
+			var unit [2]byte
+			for _, u := range utf16.AppendRune(nil, r) {
+				c.order.PutUint16(unit[:], u)
+				dst = append(dst, unit[:]...)
+			}
+		case r < 0x80:
+			dst = append(dst, byte(r))
+		default:
+			b, ok := c.low[r]
+			if !ok {
+				return dst, i, fmt.Errorf("Can't encode %q as %s", r, c.Name)
+			}
+			dst = append(dst, b)
+		}
+		i += size
+	}
+	return dst, i, nil
+}
+
+type decodingReader struct {
+	r      io.Reader
+	decode func(dst, src []byte, atEOF bool) ([]byte, int)
+	buffer []byte
+	// Bytes read but not yet decoded
+	src []byte
+	// Bytes decoded but not yet returned
+	dst []byte
+	err error
+}
+
+func (dr *decodingReader) Read(p []byte) (int, error) {
+	for len(dr.dst) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		n, err := dr.r.Read(dr.buffer)
+		dr.src = append(dr.src, dr.buffer[:n]...)
+		dr.err = err
+		var used int
+		dr.dst, used = dr.decode(dr.dst[:0], dr.src, err != nil)
+		dr.src = append(dr.src[:0], dr.src[used:]...)
+	}
+	n := copy(p, dr.dst)
+	dr.dst = dr.dst[n:]
+	return n, nil
+}
+
+type encodingWriter struct {
+	w      io.Writer
+	encode func(dst, src []byte) ([]byte, int, error)
+	// Written before the first write
+	bom []byte
+	// Part of a rune from the last write
+	src []byte
+	dst []byte
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+	if ew.bom != nil {
+		if _, err := ew.w.Write(ew.bom); err != nil {
+			return 0, err
+		}
+		ew.bom = nil
+	}
+	if ew.encode == nil {
+		return ew.w.Write(p)
+	}
+
+	ew.src = append(ew.src, p...)
+	var used int
+	var err error
+	ew.dst, used, err = ew.encode(ew.dst[:0], ew.src)
+	ew.src = append(ew.src[:0], ew.src[used:]...)
+	if _, writeErr := ew.w.Write(ew.dst); writeErr != nil {
+		return 0, writeErr
+	}
+	if err != nil {
+		ew.src = ew.src[:0]
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// A reader that only fails
+type errReader struct {
+	err error
+}
+
+func (er errReader) Read([]byte) (int, error) {
+	return 0, er.err
+}
@@ -0,0 +1,91 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing/iotest"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Charsets", func() {
+	type product struct {
+		Name  string
+		Price string
+	}
+
+	It("should match the first column behind a byte order mark", func() {
+		var output product
+		Ω(decode("\uFEFFname,price\ncafé,€3\n", &output)).Should(Succeed())
+		Ω(output).Should(Equal(product{"café", "€3"}))
+	})
+
+	It("should read nothing until the first Decode", func() {
+		input := "\uFEFFname,price\ncafé,€3\n"
+		r := strings.NewReader(input)
+		decoder := csvencoding.NewReaderDecoder(r, csvencoding.Dialect{})
+		Ω(r.Len()).Should(Equal(len(input)))
+		var output product
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(product{"café", "€3"}))
+	})
+
+	It("should read UTF-16 by its byte order mark", func() {
+		little := []byte{0xFF, 0xFE}
+		big := []byte{0xFE, 0xFF}
+		for _, r := range "name,price\ncafé,𝄞\n" {
+			if r > 0xFFFF {
+				// 𝄞 is the surrogate pair D834 DD1E
+				little = append(little, 0x34, 0xD8, 0x1E, 0xDD)
+				big = append(big, 0xD8, 0x34, 0xDD, 0x1E)
+				continue
+			}
+			little = append(little, byte(r), byte(r>>8))
+			big = append(big, byte(r>>8), byte(r))
+		}
+
+		for _, input := range [][]byte{little, big} {
+			// One byte at a time splits characters between reads
+			r := iotest.OneByteReader(bytes.NewReader(input))
+			decoder := csvencoding.NewReaderDecoder(r, csvencoding.Dialect{})
+			var output product
+			Ω(decoder.Decode(&output)).Should(Succeed())
+			Ω(output).Should(Equal(product{"café", "𝄞"}))
+		}
+	})
+
+	It("should transcode Windows-1252", func() {
+		input := "name,price\ncaf\xE9,\x803\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{Charset: csvencoding.Windows1252})
+		var output product
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(product{"café", "€3"}))
+	})
+
+	It("should write Windows-1252", func() {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.Dialect{Charset: csvencoding.Windows1252})
+		Ω(encoder.Encode(product{"café", "€3"})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("name,price\ncaf\xE9,\x803\n"))
+
+		Ω(encoder.Encode(product{"カフェ", "€3"})).Should(MatchError(`Can't encode 'カ' as Windows-1252`))
+	})
+
+	It("should round trip UTF-16 with a byte order mark", func() {
+		buffer := &bytes.Buffer{}
+		w := csv.NewWriter(csvencoding.NewCharsetWriter(buffer, csvencoding.UTF16LE, true))
+		encoder := csvencoding.NewEncoder(w)
+		Ω(encoder.EncodeHeader(product{})).Should(Succeed())
+		Ω(encoder.Encode(product{"café", "𝄞"})).Should(Succeed())
+		Ω(buffer.Bytes()[:4]).Should(Equal([]byte{0xFF, 0xFE, 'n', 0}))
+
+		r := csvencoding.NewCharsetReader(buffer, nil)
+		text, err := io.ReadAll(r)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(text)).Should(Equal("name,price\ncafé,𝄞\n"))
+	})
+})
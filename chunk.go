@@ -0,0 +1,215 @@
+package csvencoding
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Chunk is a byte range of a file that starts and ends on record
+// boundaries, so it can be decoded independently of the others
+type Chunk struct {
+	Offset int64
+	Size   int64
+}
+
+// Reader reads the chunk from r
+func (c Chunk) Reader(r io.ReaderAt) io.Reader {
+	return io.NewSectionReader(r, c.Offset, c.Size)
+}
+
+const (
+	// How far past a split point to look for a record boundary,
+	// doubled until it is found
+	chunkWindow = 64 << 10
+	// A reading of the window that finds no record start this far
+	// past the split point is taken to be wrong, it would need a
+	// quoted cell at least this long without a quote
+	maxQuotedCell = 1 << 20
+	// Give up rather than read a whole huge file
+	maxChunkWindow = 64 << 20
+)
+
+// SplitChunks divides the size bytes of r into at most n chunks aligned
+// to record boundaries. Boundaries are found by reading just past each
+// split point, a newline inside a quoted field is told apart from one
+// between records by checking which reading keeps the quoting valid.
+// Quoted cells over 1 MiB without a quote in them can't be told apart
+// from records, the split point is taken to be outside them
+func SplitChunks(r io.ReaderAt, size int64, n int, comma rune) ([]Chunk, error) {
+	if comma >= 0x80 || comma == '"' || comma == '\n' || comma == '\r' {
+		return nil, fmt.Errorf("can't split on delimiter %q", comma)
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	chunks := []Chunk{}
+	var start int64
+	for i := 1; i < n; i++ {
+		target := size * int64(i) / int64(n)
+		if target <= start {
+			continue
+		}
+		boundary, err := recordBoundary(r, target, size, byte(comma))
+		if err != nil {
+			return nil, err
+		}
+		if boundary >= size {
+			break
+		}
+		if boundary > start {
+			chunks = append(chunks, Chunk{start, boundary - start})
+			start = boundary
+		}
+	}
+	return append(chunks, Chunk{start, size - start}), nil
+}
+
+// The first record start at or after offset. The offset is either
+// outside or inside a quoted field, the window grows until one
+// reading is shown wrong and the other finds a record start
+func recordBoundary(r io.ReaderAt, offset, size int64, comma byte) (int64, error) {
+	var buf []byte
+	for window := int64(chunkWindow); ; window *= 2 {
+		if offset+window > size {
+			window = size - offset
+		}
+		// Only the growth is read
+		read := int64(len(buf))
+		buf = append(buf, make([]byte, window-read)...)
+		if _, err := r.ReadAt(buf[read:], offset+read); err != nil && err != io.EOF {
+			return 0, err
+		}
+		atEOF := offset+window >= size
+
+		outside := readWindow(buf, false, atEOF, comma)
+		inside := readWindow(buf, true, atEOF, comma)
+		if window >= maxQuotedCell && outside.found != inside.found {
+			outside.wrong = outside.wrong || !outside.found
+			inside.wrong = inside.wrong || !inside.found
+		}
+		switch {
+		case outside.found && !outside.wrong && inside.wrong:
+			return offset + int64(outside.boundary), nil
+		case inside.found && !inside.wrong && outside.wrong:
+			return offset + int64(inside.boundary), nil
+		case outside.wrong && inside.wrong:
+			return 0, fmt.Errorf("no valid record boundary after offset %d, the quoting is invalid", offset)
+		case atEOF:
+			// The last chunk takes the rest, wherever records start
+			return size, nil
+		case window >= maxChunkWindow:
+			return 0, fmt.Errorf("no record boundary can be established within %d bytes of offset %d", window, offset)
+		}
+	}
+}
+
+// A reading of a window from one quote state
+type windowReading struct {
+	// The start of the first record, when found
+	boundary int
+	found    bool
+	// The quoting is invalid
+	wrong bool
+}
+
+// The states of readWindow
+const (
+	fieldStart = iota
+	unquotedField
+	quotedField
+	// After a quote ending a quoted field, or the first of a doubled quote
+	closedField
+)
+
+// Read buf as strictly quoted records, starting inside a quoted field
+// or not. Running off the end of buf is fine unless it's the end
+// of the file, which can't be inside quotes
+func readWindow(buf []byte, quoted, atEOF bool, comma byte) windowReading {
+	var reading windowReading
+	state := fieldStart
+	if quoted {
+		state = quotedField
+	}
+	for i, c := range buf {
+		switch state {
+		case fieldStart, unquotedField:
+			switch c {
+			case '"':
+				// Unquoted fields can't contain quotes
+				if state == unquotedField {
+					reading.wrong = true
+					return reading
+				}
+				state = quotedField
+				continue
+			case comma:
+				state = fieldStart
+				continue
+			}
+		case quotedField:
+			if c == '"' {
+				state = closedField
+			}
+			continue
+		case closedField:
+			switch c {
+			case '"':
+				state = quotedField
+				continue
+			case comma:
+				state = fieldStart
+				continue
+			case '\n', '\r':
+			default:
+				reading.wrong = true
+				return reading
+			}
+		}
+		if c == '\n' {
+			state = fieldStart
+			if !reading.found {
+				reading.boundary, reading.found = i+1, true
+			}
+			continue
+		}
+		state = unquotedField
+	}
+	if atEOF {
+		if state == quotedField {
+			reading.wrong = true
+		} else if !reading.found {
+			// The rest of the file ends the record
+			reading.boundary, reading.found = len(buf), true
+		}
+	}
+	return reading
+}
+
+// ChunkDecoders splits r into at most n chunks with a Decoder for each,
+// the first reads the header and the others share it. newReader
+// configures the csv.Reader of each chunk, its Comma is used to split.
+// Line numbers in errors are relative to the start of each chunk
+func ChunkDecoders(r io.ReaderAt, size int64, n int, newReader func(io.Reader) *csv.Reader) ([]*Decoder, error) {
+	comma := newReader(strings.NewReader("")).Comma
+	chunks, err := SplitChunks(r, size, n, comma)
+	if err != nil {
+		return nil, err
+	}
+
+	decoders := make([]*Decoder, len(chunks))
+	for i, chunk := range chunks {
+		if i == 0 {
+			decoders[i] = NewDecoder(newReader(chunk.Reader(r)))
+			decoders[i].start()
+			if decoders[i].err != nil && decoders[i].err != io.EOF {
+				return nil, decoders[i].err
+			}
+			continue
+		}
+		decoders[i] = NewDecoderWithHeader(newReader(chunk.Reader(r)), decoders[0].header)
+	}
+	return decoders, nil
+}
@@ -0,0 +1,110 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chunked reading", func() {
+	type note struct {
+		ID   int
+		Body string
+		Tags []string
+	}
+
+	// Bodies full of newlines, delimiters and quotes
+	var b strings.Builder
+	b.WriteString("id,body,tags\n")
+	for i := 0; i < 20000; i++ {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "%d,plain %d,a\n", i, i)
+		case 1:
+			fmt.Fprintf(&b, "%d,\"line one\nline two, %d\n\",\"a,b\"\n", i, i)
+		case 2:
+			fmt.Fprintf(&b, "%d,\"she said \"\"hi\"\"\nthen \"\"bye\"\"\",b\n", i)
+		case 3:
+			fmt.Fprintf(&b, "%d,\"\n%d\n\",c\n", i, i)
+		}
+	}
+	input := []byte(b.String())
+
+	newReader := func(r io.Reader) *csv.Reader {
+		return csv.NewReader(r)
+	}
+
+	It("should split on record boundaries", func() {
+		for _, n := range []int{1, 2, 3, 7, 16, 64} {
+			chunks, err := csvencoding.SplitChunks(bytes.NewReader(input), int64(len(input)), n, ',')
+			Ω(err).Should(BeNil())
+			Ω(len(chunks)).Should(BeNumerically("<=", n))
+
+			var offset int64
+			for _, chunk := range chunks {
+				Ω(chunk.Offset).Should(Equal(offset))
+				offset += chunk.Size
+				if chunk.Offset > 0 {
+					Ω(input[chunk.Offset-1]).Should(Equal(byte('\n')))
+				}
+			}
+			Ω(offset).Should(Equal(int64(len(input))))
+		}
+	})
+
+	It("should decode every record exactly once", func() {
+		decoders, err := csvencoding.ChunkDecoders(bytes.NewReader(input), int64(len(input)), 16, newReader)
+		Ω(err).Should(BeNil())
+		Ω(len(decoders)).Should(BeNumerically(">", 1))
+
+		ids := []int{}
+		for _, decoder := range decoders {
+			Ω(decoder.Header()).Should(Equal([]string{"id", "body", "tags"}))
+			for {
+				var output note
+				err := decoder.Decode(&output)
+				if err == io.EOF {
+					break
+				}
+				Ω(err).Should(BeNil())
+				if output.ID%4 == 2 {
+					Ω(output.Body).Should(Equal("she said \"hi\"\nthen \"bye\""))
+				}
+				ids = append(ids, output.ID)
+			}
+		}
+		Ω(ids).Should(HaveLen(20000))
+		for i, id := range ids {
+			Ω(id).Should(Equal(i))
+		}
+	})
+
+	It("should find the end of quoted cells longer than the window", func() {
+		body := strings.Repeat("no quotes, only lines\n", 300000/22)
+		input := []byte("id,body,tags\n1,\"" + body + "\",a\n2,short,b\n")
+		decoders, err := csvencoding.ChunkDecoders(bytes.NewReader(input), int64(len(input)), 2, newReader)
+		Ω(err).Should(BeNil())
+		Ω(decoders).Should(HaveLen(2))
+
+		var output note
+		Ω(decoders[0].Decode(&output)).Should(Succeed())
+		Ω(output.Body).Should(Equal(body))
+		Ω(decoders[0].Decode(&output)).Should(Equal(io.EOF))
+		Ω(decoders[1].Decode(&output)).Should(Succeed())
+		Ω(output.ID).Should(Equal(2))
+	})
+
+	It("should keep small files whole", func() {
+		input := []byte("id,body,tags\n1,\"a\nb\",c\n")
+		chunks, err := csvencoding.SplitChunks(bytes.NewReader(input), int64(len(input)), 4, ',')
+		Ω(err).Should(BeNil())
+		Ω(chunks).Should(HaveLen(2))
+		Ω(chunks[1].Offset).Should(Equal(int64(13)))
+	})
+})
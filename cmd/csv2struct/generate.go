@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+)
+
+// A field of the generated struct, nested structs
+// come from dotted headers (person.name)
+type node struct {
+	// The header segment, used as the csv tag name
+	name   string
+	goType string
+	format string
+	// Set for nested structs
+	children []*node
+	nested   map[string]*node
+}
+
+func newNode(name string) *node {
+	return &node{name: name, nested: map[string]*node{}}
+}
+
+// Build the struct tree from the header and each column's inferred type,
+// skipped are the columns whose names can't be written in a tag
+func buildTree(header []string, columns [][]string, nilValue, emptyValue string, narrow bool) (root *node, skipped []string, err error) {
+	root = newNode("")
+	for i, name := range header {
+		if !taggable(name) {
+			skipped = append(skipped, name)
+			continue
+		}
+		parent := root
+		parts := strings.Split(name, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := parent.nested[part]
+			if !ok {
+				child = newNode(part)
+				parent.nested[part] = child
+				parent.children = append(parent.children, child)
+			}
+			if child.goType != "" {
+				return nil, nil, fmt.Errorf("column `%s` conflicts with column `%s`", name, part)
+			}
+			parent = child
+		}
+
+		part := parts[len(parts)-1]
+		if _, ok := parent.nested[part]; ok {
+			return nil, nil, fmt.Errorf("column `%s` is repeated or conflicts with nested columns", name)
+		}
+		leaf := newNode(part)
+		leaf.goType, leaf.format = inferType(columns[i], nilValue, emptyValue, narrow)
+		parent.nested[part] = leaf
+		parent.children = append(parent.children, leaf)
+	}
+	return root, skipped, nil
+}
+
+// Can name be the name of a csv tag, a comma would start the
+// options and a backquote end the tag. An = is only an option after
+// a comma so it's fine, as are the quotes %q escapes
+func taggable(name string) bool {
+	return !strings.ContainsAny(name, ",`")
+}
+
+// Print the struct named name as formatted go source
+func generate(name, pkg string, root *node) ([]byte, error) {
+	var b bytes.Buffer
+	if pkg != "" {
+		fmt.Fprintf(&b, "package %s\n\n", pkg)
+		if root.usesTime() {
+			fmt.Fprintf(&b, "import \"time\"\n\n")
+		}
+	}
+	fmt.Fprintf(&b, "type %s ", name)
+	root.write(&b)
+	b.WriteString("\n")
+	return format.Source(b.Bytes())
+}
+
+func (n *node) write(b *bytes.Buffer) {
+	if len(n.children) == 0 {
+		b.WriteString(n.goType)
+		return
+	}
+	b.WriteString("struct {\n")
+	names := map[string]int{}
+	for _, child := range n.children {
+		fieldName := goName(child.name)
+		// user id and user_id would both be UserID
+		if names[fieldName]++; names[fieldName] > 1 {
+			fieldName = fmt.Sprintf("%s%d", fieldName, names[fieldName])
+		}
+		fmt.Fprintf(b, "%s ", fieldName)
+		child.write(b)
+		tag := child.name
+		if child.format != "" {
+			tag += ",format=" + child.format
+		}
+		fmt.Fprintf(b, " `csv:%q`\n", tag)
+	}
+	b.WriteString("}")
+}
+
+func (n *node) usesTime() bool {
+	if strings.HasSuffix(n.goType, "time.Time") {
+		return true
+	}
+	for _, child := range n.children {
+		if child.usesTime() {
+			return true
+		}
+	}
+	return false
+}
+
+var initialisms = map[string]bool{
+	"API": true, "CSV": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URL": true, "UTC": true, "UUID": true,
+}
+
+// An exported go identifier for a header, user_id -> UserID
+func goName(header string) string {
+	words := strings.FieldsFunc(header, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Column" + name
+	}
+	return name
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Layouts tried when a column looks like a time, RFC 3339 needs no
+// format tag as time.Time decodes it itself
+var layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006/01/02",
+	"01/02/2006",
+	"02/01/2006",
+	"02.01.2006",
+	"15:04:05",
+}
+
+// Infer the go type of a column from its sampled cells, format
+// is the layout of times that aren't RFC 3339. Unless narrow
+// integers are int rather than the narrowest type of the sample
+func inferType(cells []string, nilValue, emptyValue string, narrow bool) (goType, format string) {
+	values := make([]string, 0, len(cells))
+	nullable := false
+	for _, cell := range cells {
+		switch cell {
+		case nilValue:
+			nullable = true
+		case emptyValue:
+		default:
+			values = append(values, cell)
+		}
+	}
+
+	// Cells holding the in cell delimiter decode as slices
+	for _, value := range values {
+		if strings.Contains(value, ",") {
+			parts := []string{}
+			for _, value := range values {
+				parts = append(parts, strings.Split(value, ",")...)
+			}
+			goType, format = inferScalar(parts, narrow)
+			return "[]" + goType, format
+		}
+	}
+
+	goType, format = inferScalar(values, narrow)
+	if nullable {
+		goType = "*" + goType
+	}
+	return goType, format
+}
+
+func inferScalar(values []string, narrow bool) (goType, format string) {
+	if len(values) == 0 {
+		return "string", ""
+	}
+	if goType, ok := inferBool(values); ok {
+		return goType, ""
+	}
+	if goType, ok := inferInt(values, narrow); ok {
+		return goType, ""
+	}
+	if goType, ok := inferFloat(values); ok {
+		return goType, ""
+	}
+	if layout, ok := inferLayout(values); ok {
+		if layout == time.RFC3339Nano {
+			layout = ""
+		}
+		return "time.Time", layout
+	}
+	return "string", ""
+}
+
+// Only the words, 1 and 0 are more likely numbers
+func inferBool(values []string) (string, bool) {
+	for _, value := range values {
+		switch strings.ToLower(value) {
+		case "true", "false":
+		default:
+			return "", false
+		}
+	}
+	return "bool", true
+}
+
+// The narrowest int or uint holding every value, or int unless narrow,
+// leading zeros (zip codes, account numbers) stay strings
+func inferInt(values []string, narrow bool) (string, bool) {
+	var min int64
+	var max uint64
+	for _, value := range values {
+		digits := strings.TrimPrefix(value, "-")
+		if hasLeadingZero(digits) {
+			return "", false
+		}
+		if u, err := strconv.ParseUint(value, 10, 64); err == nil {
+			if u > max {
+				max = u
+			}
+			continue
+		}
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", false
+		}
+		if i < min {
+			min = i
+		}
+	}
+
+	if !narrow {
+		// A sample rarely shows the whole range of a column
+		switch {
+		case max <= math.MaxInt64:
+			return "int", true
+		case min >= 0:
+			return "uint64", true
+		default:
+			return "", false
+		}
+	}
+
+	if min >= 0 {
+		switch {
+		case max <= math.MaxUint8:
+			return "uint8", true
+		case max <= math.MaxUint16:
+			return "uint16", true
+		case max <= math.MaxUint32:
+			return "uint32", true
+		default:
+			return "uint64", true
+		}
+	}
+
+	switch {
+	case max > math.MaxInt64:
+		// Mixed signs beyond int64, nothing fits
+		return "", false
+	case min >= math.MinInt8 && max <= math.MaxInt8:
+		return "int8", true
+	case min >= math.MinInt16 && max <= math.MaxInt16:
+		return "int16", true
+	case min >= math.MinInt32 && max <= math.MaxInt32:
+		return "int32", true
+	default:
+		return "int64", true
+	}
+}
+
+// 007 and 08001 are identifiers rather than numbers
+func hasLeadingZero(digits string) bool {
+	return len(digits) > 1 && digits[0] == '0' && unicode.IsDigit(rune(digits[1]))
+}
+
+func inferFloat(values []string) (string, bool) {
+	for _, value := range values {
+		// NaN and Inf parse but are rarely meant as numbers
+		if strings.IndexFunc(value, unicode.IsDigit) < 0 {
+			return "", false
+		}
+		if hasLeadingZero(strings.TrimPrefix(value, "-")) {
+			return "", false
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", false
+		}
+	}
+	return "float64", true
+}
+
+// The first layout that parses every value
+func inferLayout(values []string) (string, bool) {
+	for _, layout := range layouts {
+		ok := true
+		for _, value := range values {
+			if _, err := time.Parse(layout, value); err != nil {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return layout, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Type inference", func() {
+	infer := func(cells ...string) string {
+		goType, _ := inferType(cells, "NULL", "", false)
+		return goType
+	}
+
+	It("should infer int", func() {
+		Ω(infer("1", "255")).Should(Equal("int"))
+		Ω(infer("-1", "40000")).Should(Equal("int"))
+		Ω(infer("18446744073709551615")).Should(Equal("uint64"))
+	})
+
+	It("should pick the narrowest integer when asked", func() {
+		narrow := func(cells ...string) string {
+			goType, _ := inferType(cells, "NULL", "", true)
+			return goType
+		}
+		Ω(narrow("1", "255")).Should(Equal("uint8"))
+		Ω(narrow("1", "70000")).Should(Equal("uint32"))
+		Ω(narrow("-1", "127")).Should(Equal("int8"))
+		Ω(narrow("-1", "40000")).Should(Equal("int32"))
+	})
+
+	It("should keep leading zeros as strings", func() {
+		Ω(infer("08001", "90210")).Should(Equal("string"))
+		Ω(infer("0.5", "1")).Should(Equal("float64"))
+	})
+
+	It("should infer bools, floats and strings", func() {
+		Ω(infer("true", "FALSE")).Should(Equal("bool"))
+		Ω(infer("1.5", "2")).Should(Equal("float64"))
+		Ω(infer("henry", "2")).Should(Equal("string"))
+		Ω(infer("NaN")).Should(Equal("string"))
+	})
+
+	It("should infer time layouts", func() {
+		goType, format := inferType([]string{"2017-05-15T10:00:00Z"}, "NULL", "", false)
+		Ω(goType).Should(Equal("time.Time"))
+		Ω(format).Should(Equal(""))
+		goType, format = inferType([]string{"15/05/2017", "31/12/2017"}, "NULL", "", false)
+		Ω(goType).Should(Equal("time.Time"))
+		Ω(format).Should(Equal("02/01/2006"))
+	})
+
+	It("should make nullable columns pointers", func() {
+		Ω(infer("1", "NULL", "")).Should(Equal("*int"))
+		Ω(infer("NULL")).Should(Equal("*string"))
+	})
+
+	It("should make delimited cells slices", func() {
+		Ω(infer("1,2", "3")).Should(Equal("[]int"))
+		Ω(infer("a,b", "NULL")).Should(Equal("[]string"))
+	})
+})
+
+var _ = Describe("Generation", func() {
+	It("should nest dotted headers", func() {
+		header := []string{"user_id", "person.name", "person.born"}
+		columns := [][]string{{"1"}, {"henry"}, {"2017-05-15"}}
+		root, _, err := buildTree(header, columns, "NULL", "", false)
+		Ω(err).Should(BeNil())
+		src, err := generate("Record", "", root)
+		Ω(err).Should(BeNil())
+		Ω(string(src)).Should(Equal("type Record struct {\n" +
+			"\tUserID int `csv:\"user_id\"`\n" +
+			"\tPerson struct {\n" +
+			"\t\tName string    `csv:\"name\"`\n" +
+			"\t\tBorn time.Time `csv:\"born,format=2006-01-02\"`\n" +
+			"\t} `csv:\"person\"`\n" +
+			"}\n"))
+	})
+
+	It("should reject conflicting headers", func() {
+		_, _, err := buildTree([]string{"person", "person.name"}, [][]string{{"a"}, {"b"}}, "NULL", "", false)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should skip headers that can't be tagged", func() {
+		header := []string{"amount, usd", "rate=x", "`id`"}
+		root, skipped, err := buildTree(header, [][]string{{"1"}, {"2"}, {"3"}}, "NULL", "", false)
+		Ω(err).Should(BeNil())
+		Ω(skipped).Should(Equal([]string{"amount, usd", "`id`"}))
+		src, err := generate("Record", "", root)
+		Ω(err).Should(BeNil())
+		Ω(string(src)).Should(Equal("type Record struct {\n" +
+			"\tRateX int `csv:\"rate=x\"`\n" +
+			"}\n"))
+	})
+
+	It("should drop a byte order mark", func() {
+		f, err := os.Open("testdata/bom.csv")
+		Ω(err).Should(BeNil())
+		defer f.Close()
+		header, columns, err := sample(newReader(f, ","), 10)
+		Ω(err).Should(BeNil())
+		Ω(header).Should(Equal([]string{"id", "name"}))
+		root, _, err := buildTree(header, columns, "NULL", "", false)
+		Ω(err).Should(BeNil())
+		src, err := generate("Record", "", root)
+		Ω(err).Should(BeNil())
+		Ω(string(src)).Should(Equal("type Record struct {\n" +
+			"\tID   int    `csv:\"id\"`\n" +
+			"\tName string `csv:\"name\"`\n" +
+			"}\n"))
+	})
+
+	It("should name fields after headers", func() {
+		Ω(goName("user id")).Should(Equal("UserID"))
+		Ω(goName("first-name")).Should(Equal("FirstName"))
+		Ω(goName("2fa")).Should(Equal("Column2fa"))
+	})
+})
@@ -0,0 +1,96 @@
+// Command csv2struct samples a csv file and prints a go struct,
+// with csv tags, that csvencoding.Decoder can decode it into.
+//
+//	csv2struct -name Order orders.csv
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"github.com/hcliff/csvencoding"
+)
+
+func main() {
+	name := flag.String("name", "Record", "name of the generated struct")
+	pkg := flag.String("package", "", "print a complete file in this package")
+	rows := flag.Int("rows", 1000, "number of records to sample")
+	nilValue := flag.String("nil", csvencoding.DefaultNilValue, "cell value that decodes to nil")
+	emptyValue := flag.String("empty", csvencoding.DefaultEmptyValue, "cell value that decodes to the zero value")
+	comma := flag.String("comma", ",", "field delimiter")
+	narrow := flag.Bool("narrow", false, "use the narrowest integer types holding the sampled values, rather than int")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	header, columns, err := sample(newReader(input, *comma), *rows)
+	if err != nil {
+		fatal(err)
+	}
+
+	root, skipped, err := buildTree(header, columns, *nilValue, *emptyValue, *narrow)
+	if err != nil {
+		fatal(err)
+	}
+	for _, name := range skipped {
+		fmt.Fprintf(os.Stderr, "csv2struct: skipping column `%s`, its name can't be written in a csv tag\n", name)
+	}
+
+	src, err := generate(*name, *pkg, root)
+	if err != nil {
+		fatal(err)
+	}
+	os.Stdout.Write(src)
+}
+
+// A lenient csv.Reader of input, a byte order mark is dropped
+// so it doesn't end up in the first column's tag
+func newReader(input io.Reader, comma string) *csv.Reader {
+	r := csv.NewReader(csvencoding.NewCharsetReader(input, nil))
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	if c, _ := utf8.DecodeRuneInString(comma); c != utf8.RuneError {
+		r.Comma = c
+	}
+	return r
+}
+
+// Read the header and up to n records, transposed into columns
+func sample(r *csv.Reader, n int) (header []string, columns [][]string, err error) {
+	if header, err = r.Read(); err != nil {
+		return nil, nil, err
+	}
+	columns = make([][]string, len(header))
+	for i := 0; i < n; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := range columns {
+			if j < len(record) {
+				columns[j] = append(columns[j], record[j])
+			}
+		}
+	}
+	return header, columns, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "csv2struct:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,84 @@
+package csvencoding
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The error returned once ctx is done, it wraps ctx.Err()
+func canceled(op string, ctx context.Context) error {
+	return fmt.Errorf("%s canceled: %w", op, ctx.Err())
+}
+
+// DecodeContext is Decode that refuses to read once ctx is done, it's
+// checked between records. A blocked read of the underlying reader
+// isn't waited out, wrap it with ContextReader to stop that read too.
+// Cancellation is sticky, the error wraps ctx.Err()
+func (dec *Decoder) DecodeContext(ctx context.Context, i interface{}) error {
+	if err := dec.checkTarget(i); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		dec.err = canceled("decode", ctx)
+		return dec.err
+	}
+	return dec.Decode(i)
+}
+
+// EncodeContext is Encode that refuses to write once ctx is done,
+// cancellation is sticky, the error wraps ctx.Err()
+func (enc *Encoder) EncodeContext(ctx context.Context, i interface{}) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	if ctx.Err() != nil {
+		enc.err = canceled("encode", ctx)
+		return enc.err
+	}
+	return enc.Encode(i)
+}
+
+// ValidateContext is Validate that stops when ctx is done
+func (dec *Decoder) ValidateContext(ctx context.Context) (*ValidationReport, error) {
+	return dec.validate(func(row *map[string]interface{}) error {
+		return dec.DecodeContext(ctx, row)
+	})
+}
+
+// Readers that can interrupt a blocked Read, such as a net.Conn
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// ContextReader returns a reader that fails once ctx is done.
+// When r has a SetReadDeadline method, as a net.Conn does, a
+// blocked Read is interrupted by setting a deadline in the past.
+// Call stop once done reading, so ctx no longer holds on to r
+func ContextReader(ctx context.Context, r io.Reader) (reader io.Reader, stop func() bool) {
+	stop = func() bool { return false }
+	if deadliner, ok := r.(readDeadliner); ok {
+		stop = context.AfterFunc(ctx, func() {
+			deadliner.SetReadDeadline(time.Unix(1, 0))
+		})
+	}
+	return &contextReader{ctx: ctx, r: r}, stop
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if cr.ctx.Err() != nil {
+		return 0, canceled("read", cr.ctx)
+	}
+	n, err := cr.r.Read(p)
+	// Report the deadline set by the AfterFunc as the cancellation
+	if err != nil && cr.ctx.Err() != nil {
+		return n, canceled("read", cr.ctx)
+	}
+	return n, err
+}
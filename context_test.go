@@ -0,0 +1,126 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Context", func() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	It("should decode until the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		decoder := csvencoding.NewDecoder(reader("name,age\nbob,30\nsue,40\n"))
+
+		var output person
+		Ω(decoder.DecodeContext(ctx, &output)).Should(Succeed())
+		Ω(output).Should(Equal(person{"bob", 30}))
+
+		cancel()
+		err := decoder.DecodeContext(ctx, &output)
+		Ω(errors.Is(err, context.Canceled)).Should(BeTrue())
+		// Cancellation is sticky
+		Ω(decoder.Decode(&output)).Should(Equal(err))
+	})
+
+	It("should stop a blocked read of a wrapped connection", func() {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+		go server.Write([]byte("name,age\n"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		r, stop := csvencoding.ContextReader(ctx, client)
+		defer stop()
+		decoder := csvencoding.NewDecoder(csv.NewReader(r))
+		var output person
+		err := decoder.DecodeContext(ctx, &output)
+		Ω(errors.Is(err, context.DeadlineExceeded)).Should(BeTrue())
+	})
+
+	It("should interrupt a blocked read of a connection", func() {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		r, _ := csvencoding.ContextReader(ctx, client)
+		read := make(chan error, 1)
+		go func() {
+			_, err := r.Read(make([]byte, 10))
+			read <- err
+		}()
+		cancel()
+		Eventually(read).Should(Receive(MatchError(context.Canceled)))
+	})
+
+	It("should release the connection once stopped", func() {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, stop := csvencoding.ContextReader(ctx, client)
+		Ω(stop()).Should(BeTrue())
+		cancel()
+		// No deadline was set, the read waits for the write
+		go server.Write([]byte("x"))
+		n, err := client.Read(make([]byte, 1))
+		Ω(err).Should(BeNil())
+		Ω(n).Should(Equal(1))
+	})
+
+	It("should refuse to encode once canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewEncoder(csv.NewWriter(buffer))
+		Ω(encoder.EncodeContext(ctx, person{"bob", 30})).Should(Succeed())
+
+		cancel()
+		Ω(errors.Is(encoder.EncodeContext(ctx, person{"sue", 40}), context.Canceled)).Should(BeTrue())
+		Ω(buffer.String()).Should(Equal("bob,30\n"))
+	})
+
+	It("should stop a pipeline", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		buffer := &bytes.Buffer{}
+		stage := csvencoding.Map(func(p person) (person, error) {
+			cancel()
+			return p, nil
+		})
+		pipeline := csvencoding.NewPipeline(
+			csvencoding.NewDecoder(reader("name,age\nbob,30\nsue,40\n")),
+			csvencoding.NewEncoder(csv.NewWriter(buffer)),
+			stage,
+		)
+		Ω(errors.Is(pipeline.RunContext(ctx), context.Canceled)).Should(BeTrue())
+		Ω(buffer.String()).Should(BeEmpty())
+	})
+
+	It("should stop a parallel decoder", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		go pw.Write([]byte("id,name,score,tags\n"))
+		decoder := csvencoding.NewParallelDecoder[parallelRecord](csvencoding.NewDecoder(csv.NewReader(pr)), 2)
+
+		var output parallelRecord
+		err := decoder.DecodeContext(ctx, &output)
+		Ω(errors.Is(err, context.Canceled)).Should(BeTrue())
+		Ω(decoder.Decode(&output)).Should(Equal(err))
+	})
+})
@@ -1,36 +1,184 @@
 package csvencoding
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Decoder struct {
-	r      *csv.Reader
+	r      recordReader
 	header []string
 	err    error
 	// A cell value that translates to the types zero value
 	EmptyValue string
-	// A cell value that translates to null
+	// A cell value that translates to null, see Decode for dynamic rows
 	NilValue string
+	// Derives the column name of fields without a csv tag name
+	NameMapper NameMapper
+	// When set rows are validated against the schema
+	// and decoded into *map[string]interface{}
+	TableSchema *TableSchema
+	// Trim leading and trailing whitespace from every cell
+	TrimSpace bool
+	// Bounds on the size of records, DefaultLimits unless changed.
+	// A csv.Reader's records are only checked once read
+	Limits Limits
+	// How records with more or fewer cells than the header are decoded
+	Ragged RaggedPolicy
+	// How columns repeated in the header are decoded
+	DuplicateColumns DuplicatePolicy
+	// Records discarded before the header, such as the preamble
+	// of a bank export, they may have any number of fields
+	SkipLines int
+
+	// normalized header name -> column indexes
+	columns map[string][]int
+	// struct type -> the columns bound to its fields
+	plans map[reflect.Type]*plan
+	// TableSchema compiled against the header
+	validators []*fieldValidator
+	// Records read so far
+	row int
+	// The line the last record started on
+	line int
+	// The most levels of the header's dotted names
+	depth int
+	// A name is repeated in the header, groups
+	// are the columns sharing each column's name
+	repeated bool
+	groups   [][]int
+	// Types decoded into interfaces, see RegisterVariant
+	variants []variant
+	// The csv.Reader was configured for records of any length
+	variableFields bool
+	// Nothing is read until the first Decode, then the header
+	// unless it was given to the constructor
+	started    bool
+	readHeader bool
 }
 
-func (d Decoder) Header() []string {
-	return d.header
+// Header reads the header, if it hasn't been yet
+func (dec *Decoder) Header() []string {
+	dec.start()
+	return dec.header
 }
 
+// NewDecoder reads the header from the first record of r. It's read by
+// the first Decode, so the SkipLines and Limits set beforehand apply
 func NewDecoder(r *csv.Reader) *Decoder {
-	header, err := r.Read()
-	return &Decoder{
+	return newDecoder(r)
+}
+
+func newDecoder(r recordReader) *Decoder {
+	dec := newDecoderWithHeader(r, nil)
+	dec.readHeader = true
+	return dec
+}
+
+// Skip the preamble and read the header, once
+func (dec *Decoder) start() {
+	if dec.started {
+		return
+	}
+	dec.started = true
+	if err := dec.skipLines(); err != nil {
+		dec.err = err
+		return
+	}
+	if !dec.readHeader {
+		return
+	}
+	result := dec.readRecord()
+	header := result.record
+	if dec.TrimSpace {
+		trimCells(header)
+	}
+	dec.setHeader(header)
+	dec.err = result.err
+}
+
+// Discard the first SkipLines records, whatever their number of fields
+func (dec *Decoder) skipLines() error {
+	if dec.SkipLines <= 0 {
+		return nil
+	}
+	switch r := dec.r.(type) {
+	case *csv.Reader:
+		defer func(n int) {
+			r.FieldsPerRecord = n
+		}(r.FieldsPerRecord)
+		r.FieldsPerRecord = -1
+	case *tokenizer:
+		defer func(n int) {
+			r.fieldsPerRecord = n
+		}(r.fieldsPerRecord)
+		r.fieldsPerRecord = -1
+	}
+	for i := 0; i < dec.SkipLines; i++ {
+		if _, err := dec.r.Read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewDecoderNoHeader decodes files without a header,
+// fields are matched to columns by their index tag, csv:",index=3"
+func NewDecoderNoHeader(r *csv.Reader) *Decoder {
+	return NewDecoderWithHeader(r, nil)
+}
+
+// NewDecoderWithHeader decodes using a known header,
+// every record of r is treated as data
+func NewDecoderWithHeader(r *csv.Reader, header []string) *Decoder {
+	return newDecoderWithHeader(r, header)
+}
+
+func newDecoderWithHeader(r recordReader, header []string) *Decoder {
+	dec := &Decoder{
 		r:          r,
-		header:     header,
-		err:        err,
 		EmptyValue: DefaultEmptyValue,
 		NilValue:   DefaultNilValue,
+		NameMapper: DefaultNameMapper,
+		Limits:     DefaultLimits,
+	}
+	switch r := r.(type) {
+	case *tokenizer:
+		r.limits = &dec.Limits
+	case *csv.Reader:
+		dec.variableFields = r.FieldsPerRecord < 0
+	}
+	dec.setHeader(header)
+	return dec
+}
+
+func (dec *Decoder) setHeader(header []string) {
+	// A byte order mark read as part of the first column name
+	if len(header) > 0 && strings.HasPrefix(header[0], "\uFEFF") {
+		header = append([]string{strings.TrimPrefix(header[0], "\uFEFF")}, header[1:]...)
+	}
+	dec.header = header
+	dec.depth = headerDepth(header)
+	dec.columns = make(map[string][]int, len(header))
+	dec.plans = make(map[reflect.Type]*plan)
+	for i, name := range header {
+		key := normalizeName(name)
+		dec.columns[key] = append(dec.columns[key], i)
+	}
+	dec.repeated = len(dec.columns) < len(header)
+	dec.groups = nil
+	if dec.repeated {
+		dec.groups = make([][]int, len(header))
+		for i, name := range header {
+			dec.groups[i] = dec.columns[normalizeName(name)]
+		}
 	}
 }
 
@@ -65,7 +213,8 @@ func indirectTextUnmarshaler(v reflect.Value) encoding.TextUnmarshaler {
 	return nil
 }
 
-func (dec *Decoder) readStringTo(field reflect.Value, value string) (err error) {
+// Read a cell into field, format is the layout of time fields
+func (dec *Decoder) readStringTo(field reflect.Value, value string, format string) (err error) {
 	if value == dec.NilValue {
 		return nil
 	}
@@ -87,6 +236,16 @@ func (dec *Decoder) readStringTo(field reflect.Value, value string) (err error)
 		return nil
 	}
 
+	// Times in layouts other than RFC 3339, csv:",format=2006-01-02"
+	if format != "" && reflectType == timeType {
+		t, err := time.Parse(format, value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
 	// Handle custom csv methods
 	if setter := indirectSetter(field); setter != nil {
 		if err := setter.SetCSV([]string{value}); err != nil {
@@ -135,6 +294,12 @@ func (dec *Decoder) readStringTo(field reflect.Value, value string) (err error)
 			return err
 		}
 		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
 	case reflect.Float32:
 		f, err := strconv.ParseFloat(value, 32)
 		if err != nil {
@@ -154,11 +319,14 @@ func (dec *Decoder) readStringTo(field reflect.Value, value string) (err error)
 		}
 		field.SetBool(b)
 	case reflect.Slice:
+		if err := dec.Limits.checkSlice(value); err != nil {
+			return err
+		}
 		values := strings.Split(value, ",")
 		sliceValue := reflect.MakeSlice(reflectType, len(values), len(values))
 
 		for i, value := range values {
-			if err := dec.readStringTo(sliceValue.Index(i), value); err != nil {
+			if err := dec.readStringTo(sliceValue.Index(i), value, format); err != nil {
 				return err
 			}
 		}
@@ -172,75 +340,94 @@ func (dec *Decoder) readStringTo(field reflect.Value, value string) (err error)
 	return nil
 }
 
-func (dec *Decoder) readCellValuesTo(field reflect.Value, value *CellValues) (err error) {
-
-	// Handle pointers
-	if field.Kind() == reflect.Ptr {
-		// Instantiate a pointer to the correct underlying type
-		elem := reflect.New(field.Type().Elem())
-		// Assign said pointer to field
-		field.Set(elem)
-		field = elem.Elem()
-	}
-
-	// For now we only support decoding to struts, this could easily be fied
-	switch field.Kind() {
-	case reflect.Struct:
-		if err := dec.readStructTo(field, value); err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("Can't unmarshal %s from csv", field.Type().String())
-	}
-	if dec.err != nil {
-		return dec.err
-	}
-	return nil
+// A cell as read, a string or a view of the tokenizer's buffer
+// that mustn't be kept once the record is decoded
+type cell interface {
+	string | []byte
 }
 
-func (dec *Decoder) readStructTo(reflectValue reflect.Value, values *CellValues) (err error) {
+// A converter parses a cell into a field, chosen once per field
+// so the checks of readStringTo aren't repeated for every cell
+type converter[C cell] func(dec *Decoder, field reflect.Value, value C) error
 
-	if reflectValue.Kind() == reflect.Ptr {
-		reflectValue = reflectValue.Elem()
+// The converter of fields of type t. Numbers and bools are parsed
+// from views without a copy, everything else is read from a string
+func newConverter[C cell](t reflect.Type, format string) converter[C] {
+	// Anything but plain strings, numbers and bools
+	fallback := func(dec *Decoder, field reflect.Value, value C) error {
+		return dec.readStringTo(field, string(value), format)
 	}
 
-	reflectType := reflectValue.Type()
-
-	for i := 0; i < reflectType.NumField(); i++ {
-		fieldType := reflectType.Field(i)
-		fieldValue := reflectValue.Field(i)
+	pointer := t.Kind() == reflect.Ptr
+	if pointer {
+		t = t.Elem()
+	}
+	ptr := reflect.PtrTo(t)
+	if ptr.Implements(setterType) || ptr.Implements(textUnmarshalerType) {
+		return fallback
+	}
 
-		key := strings.Split(fieldType.Tag.Get("csv"), ",")
-		fieldName := key[0]
-		// PkgPath == "" and !Anonymous for unexported fields
-		if key[0] == "-" || (fieldType.PkgPath != "" && !fieldType.Anonymous) {
-			continue
+	var parse func(field reflect.Value, value C) error
+	switch t.Kind() {
+	case reflect.String:
+		parse = func(field reflect.Value, value C) error {
+			field.SetString(string(value))
+			return nil
 		}
-		if fieldName == "" {
-			fieldName = strings.ToLower(fieldType.Name)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		parse = func(field reflect.Value, value C) error {
+			i, err := strconv.ParseInt(string(value), 0, bits)
+			if err == nil {
+				field.SetInt(i)
+			}
+			return err
 		}
-		if fieldType.Anonymous {
-			if err := dec.readStructTo(fieldValue, values); err != nil {
-				return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bits := t.Bits()
+		parse = func(field reflect.Value, value C) error {
+			u, err := strconv.ParseUint(string(value), 0, bits)
+			if err == nil {
+				field.SetUint(u)
 			}
-		} else if cell, ok := values.Get(fieldName); ok {
-			switch cell := cell.(type) {
-			case *CellValues:
-				if err := dec.readCellValuesTo(fieldValue, cell); err != nil {
-					return err
-				}
-			case string:
-				if err := dec.readStringTo(fieldValue, cell); err != nil {
-					return err
-				}
-			default:
-				return fmt.Errorf("unexpected type %T\n", cell)
+			return err
+		}
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		parse = func(field reflect.Value, value C) error {
+			f, err := strconv.ParseFloat(string(value), bits)
+			if err == nil {
+				field.SetFloat(f)
 			}
-
+			return err
+		}
+	case reflect.Bool:
+		parse = func(field reflect.Value, value C) error {
+			b, err := strconv.ParseBool(string(value))
+			if err == nil {
+				field.SetBool(b)
+			}
+			return err
 		}
+	default:
+		return fallback
 	}
 
-	return nil
+	// The same steps as readStringTo
+	return func(dec *Decoder, field reflect.Value, value C) error {
+		if string(value) == dec.NilValue {
+			return nil
+		}
+		if pointer {
+			elem := reflect.New(t)
+			field.Set(elem)
+			field = elem.Elem()
+		}
+		if string(value) == dec.EmptyValue {
+			return nil
+		}
+		return parse(field, value)
+	}
 }
 
 // Recursive struct, where the value is either a string
@@ -276,30 +463,515 @@ func (vs CellValues) Set(key, value string) {
 	}
 }
 
+// A binding decodes one column into the field at index,
+// index is a path through nested (and embedded) structs
+type binding struct {
+	column int
+	index  []int
+	// The layout of time fields, csv:",format=2006-01-02"
+	format string
+	// Parses the cell into the field, parse is set when
+	// the field can be parsed from a view of the cell
+	convert converter[string]
+	parse   converter[[]byte]
+	// The columns of a repeated name collected into a slice
+	// field, convert parses the elements
+	collect []int
+}
+
+// Bind the column to the field of type t at index
+func newBinding(column int, index []int, t reflect.Type, format string) binding {
+	b := binding{column: column, index: index, format: format, convert: newConverter[string](t, format)}
+	if parsesViews(t) {
+		b.parse = newConverter[[]byte](t, format)
+	}
+	return b
+}
+
+// Are fields of type t parsed without keeping the cell,
+// numbers and bools that don't decode themselves
+func parsesViews(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	ptr := reflect.PtrTo(t)
+	if ptr.Implements(setterType) || ptr.Implements(textUnmarshalerType) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// The columns of the header bound to the fields of a struct type
+type plan struct {
+	bindings []binding
+	// The field collecting the cells past the header, csv:",rest"
+	rest []int
+	// Where those cells start, without a header past the last bound column
+	width int
+	// The fields of the record as read, csv:",raw", and its line, csv:",line"
+	raw, line []int
+	// The field collecting the unbound columns, csv:",extra"
+	extra   []int
+	unbound []int
+	// Fields without a column in the header
+	unmatched []unmatchedField
+	// No field keeps the record, so the tokenizer's
+	// views can be decoded without copying it
+	views bool
+}
+
+func (dec *Decoder) planFor(t reflect.Type) (*plan, error) {
+	if p, ok := dec.plans[t]; ok {
+		return p, nil
+	}
+	p := &plan{}
+	if err := dec.bind(p, t, nil, []string{""}); err != nil {
+		return nil, err
+	}
+	p.unbound = p.unboundColumns(dec.header, dec.groups)
+	p.views = p.rest == nil && p.raw == nil && p.extra == nil
+	for _, b := range p.bindings {
+		p.views = p.views && b.collect == nil
+	}
+	p.width = len(dec.header)
+	if p.width == 0 {
+		for _, b := range p.bindings {
+			if b.column >= p.width {
+				p.width = b.column + 1
+			}
+		}
+	}
+	dec.plans[t] = p
+	return p, nil
+}
+
+// Walk the fields of t binding each to a header column, prefixes are the
+// possible (dotted) names of t itself, more than one when aliased
+func (dec *Decoder) bind(p *plan, t reflect.Type, index []int, prefixes []string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if tag.skip(field) {
+			continue
+		}
+		fieldIndex := append(index[:len(index):len(index)], i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if tag.inline(field) {
+			if err := dec.bind(p, fieldType, fieldIndex, prefixes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if tag.capture() {
+			if err := p.capture(field, tag, fieldIndex); err != nil {
+				return err
+			}
+			continue
+		}
+
+		format, _ := tag.value("format")
+
+		// Positional fields ignore the header, csv:",index=3"
+		column, ok, err := tag.intValue(field, "index")
+		if err != nil {
+			return err
+		}
+		if ok {
+			p.bindings = append(p.bindings, newBinding(column, fieldIndex, field.Type, format))
+			continue
+		}
+
+		names := append([]string{tag.columnName(field, dec.NameMapper)}, tag.aliases()...)
+		keys := make([]string, 0, len(prefixes)*len(names))
+		for _, prefix := range prefixes {
+			for _, name := range names {
+				keys = append(keys, joinName(prefix, normalizeName(name)))
+			}
+		}
+
+		// Nested structs are populated from dotted columns, person.name
+		if isContainer(fieldType) {
+			if err := dec.bind(p, fieldType, fieldIndex, keys); err != nil {
+				return err
+			}
+			continue
+		}
+
+		bound := false
+		for _, key := range keys {
+			if columns, ok := dec.columns[key]; ok {
+				if err := dec.bindColumns(p, field, columns, fieldIndex, format); err != nil {
+					return err
+				}
+				bound = true
+				break
+			}
+		}
+		if !bound {
+			p.unmatched = append(p.unmatched, unmatchedField{fieldIndex, keys, tag.has("required")})
+		}
+	}
+	return nil
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	setterType          = reflect.TypeOf((*Setter)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Structs are decoded field by field unless they decode themselves
+func isContainer(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	ptr := reflect.PtrTo(t)
+	return !ptr.Implements(setterType) && !ptr.Implements(textUnmarshalerType)
+}
+
+// Like reflect.Value.FieldByIndex but nil struct pointers
+// along the way are allocated rather than panicking
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	if len(index) == 1 {
+		return v.Field(index[0])
+	}
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Decode the next record into i, a pointer to a struct or one of the
+// dynamic rows *[]string, *map[string]string, *map[string]interface{}
+// and *CellValues for files whose schema is only known at runtime.
+// Only *map[string]interface{} rows decode the NilValue as nil, the
+// others hold strings so they keep the NilValue as it was read
 func (dec *Decoder) Decode(i interface{}) error {
+	if err := dec.checkTarget(i); err != nil {
+		return err
+	}
+	if t, ok := dec.r.(*tokenizer); ok && dec.TableSchema == nil {
+		if v := reflect.ValueOf(i).Elem(); v.Kind() == reflect.Struct {
+			if p, err := dec.planFor(v.Type()); err == nil && p.views {
+				return dec.decodeView(t, p, v)
+			}
+		}
+	}
+	r, err := dec.read(dec.readRecord())
+	if err != nil {
+		return err
+	}
+	return dec.decodeRead(r, i)
+}
+
+// Can a record be decoded into i
+func (dec *Decoder) checkTarget(i interface{}) error {
+	dec.start()
 	if dec.err != nil {
 		return dec.err
 	}
 
-	// fetch the next csv row
-	var r []string
-	if r, dec.err = dec.r.Read(); dec.err != nil {
-		return dec.err
+	reflectValue := reflect.ValueOf(i)
+
+	if dec.TableSchema != nil {
+		if m, ok := i.(*map[string]interface{}); !ok || m == nil {
+			return fmt.Errorf("Can't validate csv into %T, use *map[string]interface{}", i)
+		}
 	}
 
-	// Construct a key:value map
-	// based upon the header
-	m := &CellValues{}
-	for i, key := range dec.header {
-		m.Set(key, r[i])
+	switch i.(type) {
+	case *[]string, *map[string]string, *map[string]interface{}, *CellValues:
+		if reflectValue.IsNil() {
+			return fmt.Errorf("Can't unmarshal csv into nil %T", i)
+		}
+	default:
+		if reflectValue.Kind() != reflect.Ptr || reflectValue.IsNil() {
+			return fmt.Errorf("Can't unmarshal csv into %T", i)
+		}
+		// Interfaces hold a registered variant
+		if kind := reflectValue.Elem().Kind(); kind != reflect.Struct && (kind != reflect.Interface || len(dec.variants) == 0) {
+			return fmt.Errorf("Can't unmarshal csv into %T", i)
+		}
 	}
+	return nil
+}
 
-	reflectValue := reflect.ValueOf(i)
+// A record fetched from the csv.Reader
+type readResult struct {
+	record []string
+	line   int
+	err    error
+}
 
-	// Decoder only handles root structs for now
-	if err := dec.readStructTo(reflectValue, m); err != nil {
-		dec.err = err
+// fetch the next csv row, this only touches the csv.Reader
+// so it may run on another goroutine
+func (dec *Decoder) readRecord() readResult {
+	record, err := dec.r.Read()
+	// The RaggedPolicy decides, against the header
+	if dec.header != nil && record != nil && errors.Is(err, csv.ErrFieldCount) {
+		err = nil
+	}
+	if err != nil {
+		return readResult{err: err}
+	}
+	line, _ := dec.r.FieldPos(0)
+	if err := dec.Limits.checkRecord(record); err != nil {
+		return readResult{err: &RecordError{Line: line, Err: err}}
+	}
+	return readResult{record: record, line: line}
+}
+
+// Account for a fetched record
+func (dec *Decoder) read(result readResult) ([]string, error) {
+	if result.err != nil {
+		dec.err = result.err
+		return nil, dec.err
+	}
+	dec.line = result.line
+	dec.row++
+	if dec.TrimSpace {
+		trimCells(result.record)
+	}
+	return result.record, nil
+}
+
+// Decode a fetched record into i
+func (dec *Decoder) decodeRead(r []string, i interface{}) error {
+	if dec.TableSchema != nil {
+		m := i.(*map[string]interface{})
+		if *m == nil {
+			*m = map[string]interface{}{}
+		}
+		r, err := dec.fit(r, false)
+		if err != nil {
+			dec.err = &RecordError{Line: dec.line, Err: err}
+			return dec.err
+		}
+		// Invalid rows don't stop decoding, a broken schema does
+		err = dec.validateTo(r, *m)
+		if _, ok := err.(*ValidationError); !ok {
+			dec.err = err
+		}
+		return err
 	}
 
+	if err := dec.decodeTo(r, i, dec.line); err != nil {
+		dec.err = &RecordError{Line: dec.line, Err: err}
+	}
 	return dec.err
 }
+
+// A RecordError is an error decoding the record starting on Line
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Decode a record into a dynamic row or struct pointer
+func (dec *Decoder) decodeTo(r []string, i interface{}, line int) error {
+	switch i.(type) {
+	case *map[string]interface{}, *CellValues:
+		// Dotted names are nested a level each
+		if exceeds(dec.depth, dec.Limits.MaxDepth) {
+			return &LimitError{"MaxDepth", dec.Limits.MaxDepth}
+		}
+	}
+	switch i.(type) {
+	case *[]string, *map[string]string, *map[string]interface{}, *CellValues:
+		// Structs are fit by their plan, []string takes long records whole
+		_, whole := i.(*[]string)
+		var err error
+		if r, err = dec.fit(r, whole); err != nil {
+			return err
+		}
+		if !whole {
+			if err := dec.checkRepeats(i); err != nil {
+				return err
+			}
+		}
+	}
+	switch i := i.(type) {
+	case *[]string:
+		*i = append((*i)[:0], r...)
+	case *map[string]string:
+		if *i == nil {
+			*i = make(map[string]string, len(r))
+		}
+		for j, value := range r {
+			if !dec.repeat(j) {
+				(*i)[dec.key(j)] = value
+			}
+		}
+	case *map[string]interface{}:
+		if *i == nil {
+			*i = make(map[string]interface{}, len(r))
+		}
+		for j, value := range r {
+			if dec.repeat(j) {
+				continue
+			}
+			var cell interface{} = value
+			if collected := dec.collected(j, r); collected != nil {
+				cell = collected
+			} else if value == dec.NilValue {
+				cell = nil
+			}
+			setPath(*i, dec.key(j), cell)
+		}
+	case *CellValues:
+		if *i == nil {
+			*i = CellValues{}
+		}
+		for j, value := range r {
+			if !dec.repeat(j) {
+				i.Set(dec.key(j), value)
+			}
+		}
+	default:
+		v := reflect.ValueOf(i).Elem()
+		if v.Kind() == reflect.Interface {
+			return dec.decodeVariant(r, v, line)
+		}
+		return dec.decodeRecord(r, v, line)
+	}
+	return nil
+}
+
+// The key of column i in a dynamic row,
+// its position when there is no header
+func (dec *Decoder) key(i int) string {
+	if i < len(dec.header) {
+		return dec.header[i]
+	}
+	return strconv.Itoa(i)
+}
+
+// Like CellValues.Set, dotted keys become nested maps
+func setPath(m map[string]interface{}, key string, value interface{}) {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) == 1 {
+		m[key] = value
+		return
+	}
+	child, ok := m[parts[0]].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		m[parts[0]] = child
+	}
+	setPath(child, parts[1], value)
+}
+
+// Decode the tokenizer's next record into the struct v. Numbers and
+// bools are parsed from views of its buffer, the record is only
+// copied for the fields that keep their cell, once and shared like
+// the cells csv.Reader returns. The tokenizer enforced the Limits
+func (dec *Decoder) decodeView(t *tokenizer, p *plan, v reflect.Value) error {
+	ok, err := t.read()
+	// The RaggedPolicy decides, against the header
+	if ok && dec.header != nil && errors.Is(err, csv.ErrFieldCount) {
+		err = nil
+	}
+	if err != nil {
+		dec.err = err
+		return dec.err
+	}
+	dec.line, _ = t.FieldPos(0)
+	dec.row++
+
+	fields := t.fields()
+	err = dec.checkFit(fields, false)
+	var record string
+	copied := false
+	for _, b := range p.bindings {
+		if err != nil {
+			break
+		}
+		field := fieldByIndex(v, b.index)
+		switch {
+		case b.column < fields && b.parse != nil:
+			cell := t.cell(b.column)
+			if dec.TrimSpace {
+				cell = bytes.TrimSpace(cell)
+			}
+			err = b.parse(dec, field, cell)
+		case b.column < fields:
+			if !copied {
+				record, copied = string(t.recordBuffer), true
+			}
+			start, end := t.bounds(b.column)
+			cell := record[start:end]
+			if dec.TrimSpace {
+				cell = strings.TrimSpace(cell)
+			}
+			err = b.convert(dec, field, cell)
+		default:
+			if pad, ok := dec.padValue(); ok {
+				err = b.convert(dec, field, pad)
+			} else {
+				err = fmt.Errorf("column %d out of range, the record has %d fields", b.column, fields)
+			}
+		}
+	}
+	if err != nil {
+		dec.err = &RecordError{Line: dec.line, Err: err}
+		return dec.err
+	}
+	dec.captureRecord(p, v, nil, nil, dec.line)
+	return nil
+}
+
+// Decode a single record, raw as read from line, into the struct v
+func (dec *Decoder) decodeRecord(raw []string, v reflect.Value, line int) error {
+	p, err := dec.planFor(v.Type())
+	if err != nil {
+		return err
+	}
+	r, err := dec.fit(raw, p.rest != nil)
+	if err != nil {
+		return err
+	}
+	for _, b := range p.bindings {
+		if b.collect != nil {
+			if err := dec.collect(fieldByIndex(v, b.index), r, b); err != nil {
+				return err
+			}
+			continue
+		}
+		cell, ok := "", b.column < len(r)
+		if ok {
+			cell = r[b.column]
+		} else if cell, ok = dec.padValue(); !ok {
+			return fmt.Errorf("column %d out of range, the record has %d fields", b.column, len(r))
+		}
+		if err := b.convert(dec, fieldByIndex(v, b.index), cell); err != nil {
+			return err
+		}
+	}
+	dec.captureRecord(p, v, raw, r, line)
+	return nil
+}
@@ -105,6 +105,30 @@ var _ = Describe("CSV Decoding", func() {
 		Ω(output.Time).Should(BeTemporally("==", expectedOutput))
 	})
 
+	It("should decode unsigned ints", func() {
+		input := "small,big\n255,18446744073709551615"
+		output := struct {
+			Small uint8
+			Big   uint64
+		}{}
+		err = decode(input, &output)
+		Ω(err).Should(BeNil())
+		Ω(output.Small).Should(Equal(uint8(255)))
+		Ω(output.Big).Should(Equal(uint64(18446744073709551615)))
+	})
+
+	It("should decode time with a format tag", func() {
+		input := "born,died\n15/05/1990,NULL\n"
+		output := struct {
+			Born time.Time  `csv:",format=02/01/2006"`
+			Died *time.Time `csv:",format=02/01/2006"`
+		}{}
+		err = decode(input, &output)
+		Ω(err).Should(BeNil())
+		Ω(output.Born).Should(BeTemporally("==", time.Date(1990, 5, 15, 0, 0, 0, 0, time.UTC)))
+		Ω(output.Died).Should(BeNil())
+	})
+
 	It("Should decode custom types", func() {
 		input := "json\nhello world"
 		output := struct {
@@ -164,6 +188,169 @@ var _ = Describe("CSV Decoding", func() {
 		Ω(output.Name).Should(Equal("henry"))
 	})
 
+	Context("Header matching", func() {
+		It("should ignore case and whitespace", func() {
+			input := " User ID ,NAME\n23,henry"
+			output := struct {
+				UserID int
+				Name   string
+			}{}
+			err = decode(input, &output)
+			Ω(err).Should(BeNil())
+			Ω(output.UserID).Should(Equal(23))
+			Ω(output.Name).Should(Equal("henry"))
+		})
+
+		It("should ignore underscores and dashes", func() {
+			for _, mapper := range []csvencoding.NameMapper{csvencoding.LowerCase, csvencoding.SnakeCase, csvencoding.KebabCase} {
+				for _, column := range []string{"User ID", "user_id", "UserId", "user-id"} {
+					output := struct {
+						UserID int
+					}{}
+					decoder := csvencoding.NewDecoder(reader(column + "\n23"))
+					decoder.NameMapper = mapper
+					Ω(decoder.Decode(&output)).Should(Succeed())
+					Ω(output.UserID).Should(Equal(23))
+				}
+			}
+		})
+
+		It("should derive names with a NameMapper", func() {
+			input := "user_id,first_name\n23,henry"
+			output := struct {
+				UserID    int
+				FirstName string
+			}{}
+			decoder := csvencoding.NewDecoder(reader(input))
+			decoder.NameMapper = csvencoding.SnakeCase
+			err = decoder.Decode(&output)
+			Ω(err).Should(BeNil())
+			Ω(output.UserID).Should(Equal(23))
+			Ω(output.FirstName).Should(Equal("henry"))
+		})
+
+		It("should match aliases", func() {
+			input := "uid,person.handle\n23,henry"
+			type personStruct struct {
+				Name string `csv:"name,alias=handle"`
+			}
+			output := struct {
+				UserID int `csv:"user_id,alias=uid|UserId"`
+				Person personStruct
+			}{}
+			err = decode(input, &output)
+			Ω(err).Should(BeNil())
+			Ω(output.UserID).Should(Equal(23))
+			Ω(output.Person.Name).Should(Equal("henry"))
+		})
+	})
+
+	Context("Positional columns", func() {
+		type positional struct {
+			Name string `csv:",index=2"`
+			Age  int    `csv:",index=0"`
+		}
+
+		It("should decode files without a header", func() {
+			output := positional{}
+			decoder := csvencoding.NewDecoderNoHeader(reader("23,x,henry\n"))
+			err = decoder.Decode(&output)
+			Ω(err).Should(BeNil())
+			Ω(decoder.Header()).Should(BeNil())
+			Ω(output).Should(Equal(positional{Name: "henry", Age: 23}))
+		})
+
+		It("should decode with a known header", func() {
+			output := struct {
+				Name string
+				Age  int `csv:",index=1"`
+			}{}
+			decoder := csvencoding.NewDecoderWithHeader(reader("henry,23\n"), []string{"name", "years"})
+			err = decoder.Decode(&output)
+			Ω(err).Should(BeNil())
+			Ω(output.Name).Should(Equal("henry"))
+			Ω(output.Age).Should(Equal(23))
+		})
+
+		It("should skip preamble lines", func() {
+			input := "Statement for account 1234\nExported,2017-05-15\nage,name\n23,henry\n"
+			for _, decoder := range []*csvencoding.Decoder{
+				csvencoding.NewDecoder(reader(input)),
+				csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{}),
+			} {
+				decoder.SkipLines = 2
+				output := struct {
+					Name string
+					Age  int
+				}{}
+				err = decoder.Decode(&output)
+				Ω(err).Should(BeNil())
+				Ω(decoder.Header()).Should(Equal([]string{"age", "name"}))
+				Ω(output.Name).Should(Equal("henry"))
+				Ω(output.Age).Should(Equal(23))
+			}
+		})
+
+		It("should skip preamble lines of files without a header", func() {
+			decoder := csvencoding.NewDecoderNoHeader(reader("Statement for account 1234\n23,x,henry\n"))
+			decoder.SkipLines = 1
+			output := positional{}
+			Ω(decoder.Decode(&output)).Should(Succeed())
+			Ω(output).Should(Equal(positional{Name: "henry", Age: 23}))
+		})
+
+		It("should error on an index beyond the record", func() {
+			output := positional{}
+			err = csvencoding.NewDecoderNoHeader(reader("23,henry\n")).Decode(&output)
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Dynamic rows", func() {
+		input := "name,person.age\nhenry,NULL"
+
+		It("should decode into a slice", func() {
+			output := []string{}
+			err = decode(input, &output)
+			Ω(err).Should(BeNil())
+			Ω(output).Should(Equal([]string{"henry", "NULL"}))
+		})
+
+		It("should decode into a string map", func() {
+			var output map[string]string
+			err = decode(input, &output)
+			Ω(err).Should(BeNil())
+			Ω(output).Should(Equal(map[string]string{"name": "henry", "person.age": "NULL"}))
+		})
+
+		It("should decode into a nested map", func() {
+			output := map[string]interface{}{}
+			err = decode(input, &output)
+			Ω(err).Should(BeNil())
+			Ω(output).Should(Equal(map[string]interface{}{
+				"name":   "henry",
+				"person": map[string]interface{}{"age": nil},
+			}))
+		})
+
+		It("should decode into cell values", func() {
+			output := csvencoding.CellValues{}
+			err = decode(input, &output)
+			Ω(err).Should(BeNil())
+			Ω(output).Should(Equal(csvencoding.CellValues{
+				"name":   "henry",
+				"person": &csvencoding.CellValues{"age": "NULL"},
+			}))
+		})
+
+		It("should key header-less rows by position", func() {
+			var output map[string]string
+			err = csvencoding.NewDecoderNoHeader(reader("henry,23")).Decode(&output)
+			Ω(err).Should(BeNil())
+			Ω(output).Should(Equal(map[string]string{"0": "henry", "1": "23"}))
+		})
+	})
+
 	Context("Nested structs", func() {
 		input := "person.name\nhenry"
 		type personStruct struct {
@@ -0,0 +1,91 @@
+package csvencoding
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Dialect describes how a csv file is laid out, it configures the
+// reader and writer built by NewReaderDecoder and NewWriterEncoder
+type Dialect struct {
+	// The field delimiter, ',' when zero
+	Comma rune
+	// The quote character, '"' when zero
+	Quote rune
+	// Lines starting with Comment are ignored when reading, 0 disables
+	Comment rune
+	// Allow quotes in unquoted fields and non-doubled quotes in quoted ones
+	LazyQuotes bool
+	// Terminate written lines with \r\n instead of \n
+	UseCRLF bool
+	// The first record is data, fields are matched by their index tag
+	NoHeader bool
+	// Trim leading and trailing whitespace from every cell read
+	TrimSpace bool
+	// Write a byte order mark, a leading one is always dropped when reading
+	BOM bool
+	// The encoding of the file when it has no byte order mark, nil is UTF-8
+	Charset *Charset
+}
+
+var (
+	// RFC 4180, comma separated with CRLF line endings
+	RFC4180 = Dialect{Comma: ',', UseCRLF: true}
+	// Excel's "CSV UTF-8", the BOM makes Excel read it as UTF-8,
+	// set the Charset to Windows1252 for its plain "CSV"
+	Excel = Dialect{Comma: ',', UseCRLF: true, BOM: true, LazyQuotes: true}
+	// Excel in locales where the comma is the decimal separator
+	ExcelSemicolon = Dialect{Comma: ';', UseCRLF: true, BOM: true, LazyQuotes: true}
+	// Tab separated values, quotes are common in unquoted cells
+	TSV = Dialect{Comma: '\t', LazyQuotes: true}
+	// COPY ... WITH (FORMAT csv, HEADER) in PostgreSQL, comma separated
+	// with \n line endings. Set the NilValue of the Decoder or Encoder
+	// to "" to match its NULL, an unquoted empty cell
+	PostgresCSV = Dialect{Comma: ',', Quote: '"'}
+)
+
+// NewReaderDecoder decodes r as described by d
+func NewReaderDecoder(r io.Reader, d Dialect) *Decoder {
+	t := newTokenizer(NewCharsetReader(r, d.Charset))
+	if d.Comma != 0 {
+		t.comma = d.Comma
+	}
+	if d.Quote != 0 {
+		t.quote = utf8.AppendRune(nil, d.Quote)
+	}
+	t.comment = d.Comment
+	t.lazyQuotes = d.LazyQuotes
+	t.trimLeadingSpace = d.TrimSpace
+
+	dec := newDecoderWithHeader(t, nil)
+	dec.readHeader = !d.NoHeader
+	dec.TrimSpace = d.TrimSpace
+	return dec
+}
+
+// NewWriterEncoder encodes to w as described by d, unless d.NoHeader
+// the header is written before the first record
+func NewWriterEncoder(w io.Writer, d Dialect) *Encoder {
+	if d.BOM || d.Charset != nil {
+		w = NewCharsetWriter(w, d.Charset, d.BOM)
+	}
+	out := newWriter(w)
+	if d.Comma != 0 {
+		out.comma = d.Comma
+	}
+	if d.Quote != 0 {
+		out.quote = d.Quote
+	}
+	out.useCRLF = d.UseCRLF
+
+	enc := newEncoder(out)
+	enc.WriteHeader = !d.NoHeader
+	return enc
+}
+
+func trimCells(record []string) {
+	for i, cell := range record {
+		record[i] = strings.TrimSpace(cell)
+	}
+}
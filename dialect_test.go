@@ -0,0 +1,93 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dialect", func() {
+	type person struct {
+		Name string
+		Age  int
+	}
+
+	It("should decode an Excel file", func() {
+		input := "\xEF\xBB\xBFname;age\r\nbob;30\r\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.ExcelSemicolon)
+		var output person
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(person{"bob", 30}))
+	})
+
+	It("should encode an Excel file", func() {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.Excel)
+		Ω(encoder.Encode(person{"bob", 30})).Should(Succeed())
+		Ω(encoder.Encode(person{"sue", 40})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("\xEF\xBB\xBFname,age\r\nbob,30\r\nsue,40\r\n"))
+	})
+
+	It("should round trip PostgreSQL's COPY csv", func() {
+		type row struct {
+			ID   int
+			Name string
+			Note *string
+		}
+		input := "id,name,note\n1,\"Smith, J\",\"said \"\"hi\"\"\nthen left\"\n2,bob,\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.PostgresCSV)
+		decoder.NilValue = ""
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.PostgresCSV)
+		encoder.NilValue = ""
+		var rows []row
+		for {
+			var r row
+			if decoder.Decode(&r) != nil {
+				break
+			}
+			rows = append(rows, r)
+			Ω(encoder.Encode(r)).Should(Succeed())
+		}
+		Ω(rows).Should(HaveLen(2))
+		Ω(*rows[0].Note).Should(Equal("said \"hi\"\nthen left"))
+		Ω(rows[1].Note).Should(BeNil())
+		Ω(buffer.String()).Should(Equal(input))
+	})
+
+	It("should decode tab separated values with comments", func() {
+		dialect := csvencoding.TSV
+		dialect.Comment = '#'
+		input := "# exported today\nname\tage\nbob \"the builder\"\t30\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), dialect)
+		var output person
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(person{`bob "the builder"`, 30}))
+	})
+
+	It("should trim cells", func() {
+		input := " name , age \n bob , 30 \n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{TrimSpace: true})
+		var output map[string]string
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(map[string]string{"name": "bob", "age": "30"}))
+	})
+
+	It("should read and write files without a header", func() {
+		type indexed struct {
+			Name string `csv:",index=0"`
+			Age  int    `csv:",index=1"`
+		}
+		dialect := csvencoding.Dialect{NoHeader: true}
+		buffer := &bytes.Buffer{}
+		Ω(csvencoding.NewWriterEncoder(buffer, dialect).Encode(indexed{"bob", 30})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("bob,30\n"))
+
+		var output indexed
+		Ω(csvencoding.NewReaderDecoder(buffer, dialect).Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(indexed{"bob", 30}))
+	})
+})
@@ -0,0 +1,138 @@
+package csvencoding
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A DuplicatePolicy decides how a column repeated in the header,
+// tag,tag,tag, is decoded. Names are compared like field names,
+// ignoring case, whitespace, _ and -
+type DuplicatePolicy int
+
+const (
+	// The last of the columns is decoded, the historic behaviour
+	DuplicateLastWins DuplicatePolicy = iota
+	// The first of the columns is decoded
+	DuplicateFirstWins
+	// Fail the Decode
+	DuplicateError
+	// Slice fields receive a cell of each of the columns,
+	// dynamic rows a []string, anything else fails
+	DuplicateCollect
+)
+
+// Duplicates reports the columns repeated in the header,
+// by their first name, with the index of each repeat
+func (dec *Decoder) Duplicates() map[string][]int {
+	dec.start()
+	duplicates := map[string][]int{}
+	for _, columns := range dec.columns {
+		if len(columns) > 1 {
+			duplicates[dec.header[columns[0]]] = columns
+		}
+	}
+	return duplicates
+}
+
+func duplicateError(name string, columns []int) error {
+	positions := make([]string, len(columns))
+	for i, column := range columns {
+		positions[i] = strconv.Itoa(column)
+	}
+	return fmt.Errorf("column `%s` is repeated, at %s", name, strings.Join(positions, ", "))
+}
+
+// Bind a field to the columns of its name by the DuplicatePolicy
+func (dec *Decoder) bindColumns(p *plan, field reflect.StructField, columns []int, index []int, format string) error {
+	column := columns[len(columns)-1]
+	if len(columns) > 1 {
+		switch dec.DuplicateColumns {
+		case DuplicateFirstWins:
+			column = columns[0]
+		case DuplicateError:
+			return duplicateError(dec.header[columns[0]], columns)
+		case DuplicateCollect:
+			return dec.bindCollect(p, field, columns, index, format)
+		}
+	}
+	p.bindings = append(p.bindings, newBinding(column, index, field.Type, format))
+	return nil
+}
+
+// Bind a slice field to a cell of each of the columns,
+// convert parses the cells into the elements
+func (dec *Decoder) bindCollect(p *plan, field reflect.StructField, columns []int, index []int, format string) error {
+	if field.Type.Kind() != reflect.Slice || isContainer(field.Type.Elem()) {
+		return fmt.Errorf("%s, collect it into a slice field not %s", duplicateError(dec.header[columns[0]], columns), field.Type)
+	}
+	convert := newConverter[string](field.Type.Elem(), format)
+	p.bindings = append(p.bindings, binding{column: columns[0], collect: columns, index: index, format: format, convert: convert})
+	return nil
+}
+
+// Decode a cell of each of the collected columns into the slice field
+func (dec *Decoder) collect(field reflect.Value, r []string, b binding) error {
+	slice := reflect.MakeSlice(field.Type(), 0, len(b.collect))
+	for _, column := range b.collect {
+		if column >= len(r) {
+			continue
+		}
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := b.convert(dec, elem, r[column]); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+	}
+	field.Set(slice)
+	return nil
+}
+
+// Can the repeated columns of the header be decoded into the dynamic row i
+func (dec *Decoder) checkRepeats(i interface{}) error {
+	if !dec.repeated {
+		return nil
+	}
+	switch dec.DuplicateColumns {
+	case DuplicateError:
+		for _, group := range dec.groups {
+			if len(group) > 1 {
+				return duplicateError(dec.header[group[0]], group)
+			}
+		}
+	case DuplicateCollect:
+		if _, ok := i.(*map[string]interface{}); !ok {
+			return fmt.Errorf("Can't collect repeated columns into %T, use *map[string]interface{}", i)
+		}
+	}
+	return nil
+}
+
+// Is column j a repeat a dynamic row skips, the DuplicatePolicy
+// picks the column decoded, or collected, of those repeated
+func (dec *Decoder) repeat(j int) bool {
+	if !dec.repeated || j >= len(dec.groups) {
+		return false
+	}
+	group := dec.groups[j]
+	if dec.DuplicateColumns == DuplicateLastWins {
+		return j != group[len(group)-1]
+	}
+	return j != group[0]
+}
+
+// The cells of column j's repeats when they are collected
+func (dec *Decoder) collected(j int, r []string) []string {
+	if !dec.repeated || dec.DuplicateColumns != DuplicateCollect || j >= len(dec.groups) || len(dec.groups[j]) < 2 {
+		return nil
+	}
+	cells := make([]string, 0, len(dec.groups[j]))
+	for _, column := range dec.groups[j] {
+		if column < len(r) {
+			cells = append(cells, r[column])
+		}
+	}
+	return cells
+}
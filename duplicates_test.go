@@ -0,0 +1,93 @@
+package csvencoding_test
+
+import (
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Repeated columns", func() {
+	type post struct {
+		Email string
+		Tags  []string `csv:"tag"`
+	}
+	input := "email,tag,Email,tag,tag\na@x.com,go,b@x.com,csv,\"a,b\"\n"
+
+	decode := func(policy csvencoding.DuplicatePolicy, output interface{}) error {
+		decoder := csvencoding.NewDecoder(reader(input))
+		decoder.DuplicateColumns = policy
+		return decoder.Decode(output)
+	}
+
+	It("should report repeated columns", func() {
+		decoder := csvencoding.NewDecoder(reader(input))
+		Ω(decoder.Duplicates()).Should(Equal(map[string][]int{"email": {0, 2}, "tag": {1, 3, 4}}))
+		decoder = csvencoding.NewDecoder(reader("a,b\n1,2\n"))
+		Ω(decoder.Duplicates()).Should(BeEmpty())
+	})
+
+	It("should decode the last or first column", func() {
+		var output post
+		Ω(decode(csvencoding.DuplicateLastWins, &output)).Should(Succeed())
+		Ω(output).Should(Equal(post{"b@x.com", []string{"a", "b"}}))
+
+		Ω(decode(csvencoding.DuplicateFirstWins, &output)).Should(Succeed())
+		Ω(output).Should(Equal(post{"a@x.com", []string{"go"}}))
+
+		row := map[string]string{}
+		Ω(decode(csvencoding.DuplicateFirstWins, &row)).Should(Succeed())
+		Ω(row).Should(Equal(map[string]string{"email": "a@x.com", "tag": "go"}))
+	})
+
+	It("should fail on repeated columns", func() {
+		var output post
+		Ω(decode(csvencoding.DuplicateError, &output)).Should(MatchError("line 2: column `email` is repeated, at 0, 2"))
+		Ω(decode(csvencoding.DuplicateError, &map[string]string{})).Should(MatchError("line 2: column `email` is repeated, at 0, 2"))
+
+		// Unless no field reads them
+		type tagged struct {
+			Tag []string
+		}
+		decoder := csvencoding.NewDecoder(reader("email,email,tag\na,b,c\n"))
+		decoder.DuplicateColumns = csvencoding.DuplicateError
+		Ω(decoder.Decode(&tagged{})).Should(Succeed())
+	})
+
+	It("should collect repeated columns", func() {
+		type tagged struct {
+			Email []string
+			Tags  []string          `csv:"tag"`
+			Extra map[string]string `csv:",extra"`
+		}
+		var output tagged
+		Ω(decode(csvencoding.DuplicateCollect, &output)).Should(Succeed())
+		Ω(output).Should(Equal(tagged{[]string{"a@x.com", "b@x.com"}, []string{"go", "csv", "a,b"}, nil}))
+
+		row := map[string]interface{}{}
+		Ω(decode(csvencoding.DuplicateCollect, &row)).Should(Succeed())
+		Ω(row).Should(Equal(map[string]interface{}{
+			"email": []string{"a@x.com", "b@x.com"},
+			"tag":   []string{"go", "csv", "a,b"},
+		}))
+
+		Ω(decode(csvencoding.DuplicateCollect, &post{})).Should(MatchError("line 2: column `email` is repeated, at 0, 2, collect it into a slice field not string"))
+		Ω(decode(csvencoding.DuplicateCollect, &map[string]string{})).Should(MatchError("line 2: Can't collect repeated columns into *map[string]string, use *map[string]interface{}"))
+	})
+
+	It("should not capture the repeats a policy drops", func() {
+		type captured struct {
+			A     string
+			Extra map[string]string `csv:",extra"`
+		}
+		for policy, a := range map[csvencoding.DuplicatePolicy]string{
+			csvencoding.DuplicateLastWins:  "2",
+			csvencoding.DuplicateFirstWins: "1",
+		} {
+			decoder := csvencoding.NewDecoder(reader("a,a,b\n1,2,3\n"))
+			decoder.DuplicateColumns = policy
+			var output captured
+			Ω(decoder.Decode(&output)).Should(Succeed())
+			Ω(output).Should(Equal(captured{a, map[string]string{"b": "3"}}))
+		}
+	})
+})
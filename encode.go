@@ -5,24 +5,58 @@ import (
 	"encoding/csv"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Encoder struct {
-	w   *csv.Writer
+	w   recordWriter
 	err error
 	// A cell value to be used when omitempty is specified on a reflectValue
 	EmptyValue string
 	// A cell value to be used for nil values
 	NilValue string
+	// Derives the column name of fields without a csv tag name
+	NameMapper NameMapper
+	// Write the header of the first record encoded before it
+	WriteHeader bool
+	// How cells a spreadsheet would evaluate as formulas are written
+	SanitizeFormulas SanitizePolicy
+	// Write numbers a spreadsheet would mangle, long IDs and those
+	// with leading zeros, as formulas evaluating to their text
+	ProtectNumbers bool
+	// Which cells are quoted, only QuoteMinimal is
+	// supported when writing to a csv.Writer
+	Quoting QuotePolicy
+	// Precedes quotes in quoted cells instead of doubling them,
+	// and special characters in cells when Quoting is QuoteNone
+	Escape rune
+
+	// The header has been written
+	wroteHeader bool
+	// Dotted paths chosen by Columns
+	projection []string
+	// The column order of map rows, from the first one encoded
+	rowKeys []string
+	// type -> where its cells are written
+	layouts map[reflect.Type]*layout
+	// Types written under a union header, see RegisterVariant
+	variants []variant
+	union    *union
 }
 
 func NewEncoder(w *csv.Writer) *Encoder {
+	return newEncoder(w)
+}
+
+func newEncoder(w recordWriter) *Encoder {
 	return &Encoder{
 		w:          w,
 		EmptyValue: DefaultEmptyValue,
 		NilValue:   DefaultNilValue,
+		NameMapper: DefaultNameMapper,
 	}
 }
 
@@ -59,7 +93,15 @@ func indirectTextMarshaler(v reflect.Value) encoding.TextMarshaler {
 	return nil
 }
 
-func (enc Encoder) marshal(reflectValue reflect.Value, omitEmpty bool) (s []string, err error) {
+func (enc *Encoder) marshal(reflectValue reflect.Value, omitEmpty bool) (s []string, err error) {
+
+	// Values held in interfaces, map[string]interface{}
+	if reflectValue.Kind() == reflect.Interface {
+		if reflectValue.IsNil() {
+			return []string{enc.NilValue}, nil
+		}
+		reflectValue = reflectValue.Elem()
+	}
 
 	if getter := indirectGetter(reflectValue); getter != nil {
 		return getter.GetCSV()
@@ -75,18 +117,16 @@ func (enc Encoder) marshal(reflectValue reflect.Value, omitEmpty bool) (s []stri
 
 	if reflectValue.Kind() == reflect.Ptr {
 		if reflectValue.IsNil() {
-			switch e := reflectValue.Type().Elem(); e.Kind() {
-			case reflect.Struct:
-				output := make([]string, 0, e.NumField())
-				for i := 0; i < e.NumField(); i++ {
-					if tmp := e.Field(i); tmp.PkgPath == "" {
-						output = append(output, enc.NilValue)
-					}
-				}
-				return output, nil
-			default:
-				return []string{enc.NilValue}, nil
+			// A nil struct still takes up all of its columns
+			columns, err := enc.columns(reflectValue.Type().Elem(), "")
+			if err != nil {
+				return nil, err
 			}
+			output := make([]string, len(columns))
+			for i := range output {
+				output[i] = enc.NilValue
+			}
+			return output, nil
 		}
 		reflectValue = reflectValue.Elem()
 	}
@@ -109,6 +149,9 @@ func (enc Encoder) marshal(reflectValue reflect.Value, omitEmpty bool) (s []stri
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return []string{strconv.FormatInt(reflectValue.Int(), 10)}, nil
 
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []string{strconv.FormatUint(reflectValue.Uint(), 10)}, nil
+
 	case reflect.Float32, reflect.Float64:
 		return []string{strconv.FormatFloat(reflectValue.Float(), 'f', -1, 64)}, nil
 
@@ -170,18 +213,13 @@ func (enc Encoder) marshal(reflectValue reflect.Value, omitEmpty bool) (s []stri
 		for i := 0; i < reflectType.NumField(); i++ {
 			field := reflectType.Field(i)
 
-			key := strings.Split(field.Tag.Get("csv"), ",")
-			fieldName := key[0]
-			// csv:",omitEmpty"
-			omitEmpty := len(key) > 1 && key[1] == "omitEmpty"
-
-			// PkgPath == "" and !Anonymous for unexported reflectValues
-			if fieldName == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			tag := parseTag(field)
+			if tag.skip(field) || tag.capture() {
 				continue
 			}
 
 			fieldValue := reflectValue.Field(i)
-			fieldOutput, err := enc.marshal(fieldValue, omitEmpty)
+			fieldOutput, err := enc.marshalField(fieldValue, tag)
 			if err != nil {
 				err = fmt.Errorf("struct field `%s`: `%v`: %s", field.Name, fieldValue.Interface(), err.Error())
 				return nil, err
@@ -195,21 +233,523 @@ func (enc Encoder) marshal(reflectValue reflect.Value, omitEmpty bool) (s []stri
 	}
 }
 
-func (enc Encoder) Encode(i interface{}) error {
+// Marshal a struct field according to its tag
+func (enc *Encoder) marshalField(fieldValue reflect.Value, tag tagOptions) ([]string, error) {
+	// csv:",omitEmpty"
+	omitEmpty := tag.has("omitEmpty")
+
+	// Times in layouts other than RFC 3339, csv:",format=2006-01-02"
+	if format, ok := tag.value("format"); ok {
+		t := fieldValue
+		if t.Kind() == reflect.Ptr && t.Type().Elem() == timeType {
+			if t.IsNil() {
+				return []string{enc.NilValue}, nil
+			}
+			t = t.Elem()
+		}
+		if t.Type() == timeType {
+			if omitEmpty && t.Interface().(time.Time).IsZero() {
+				return []string{enc.EmptyValue}, nil
+			}
+			return []string{t.Interface().(time.Time).Format(format)}, nil
+		}
+	}
+
+	return enc.marshal(fieldValue, omitEmpty)
+}
+
+// Encode writes i as a record, i is a struct or one of the
+// dynamic rows []string, map[string]string or map[string]interface{}
+func (enc *Encoder) Encode(i interface{}) error {
 	if enc.err != nil {
 		return enc.err
 	}
 
-	reflectValue := reflect.ValueOf(i)
+	// []string rows are written as is, the first is the header
+	if enc.WriteHeader && !enc.wroteHeader {
+		if _, ok := i.([]string); !ok {
+			if err := enc.EncodeHeader(i); err != nil {
+				return err
+			}
+		}
+		enc.wroteHeader = true
+	}
 
-	output, err := enc.marshal(reflectValue, false)
+	record, err := enc.record(reflect.ValueOf(i))
+	var numeric []bool
+	if err == nil && enc.Quoting == QuoteNonNumeric {
+		numeric, err = enc.numericCells(reflect.ValueOf(i))
+	}
 	if err != nil {
 		enc.err = err
 		return enc.err
 	}
 
-	enc.err = enc.w.Write(output)
-	enc.w.Flush()
+	return enc.write(record, numeric)
+}
 
+// Write and flush a record, failures of the underlying
+// writer only surface on Flush. numeric marks the cells
+// of numeric fields for QuoteNonNumeric
+func (enc *Encoder) write(record []string, numeric []bool) error {
+	if w, ok := enc.w.(*writer); ok {
+		enc.err = w.writeRecord(record, enc.Quoting, enc.Escape, numeric)
+	} else if enc.Quoting != QuoteMinimal || enc.Escape != 0 {
+		enc.err = fmt.Errorf("Can't apply quoting policies to a csv.Writer, use NewWriterEncoder")
+	} else {
+		enc.err = enc.w.Write(record)
+	}
+	if enc.err == nil {
+		enc.w.Flush()
+		enc.err = enc.w.Error()
+	}
 	return enc.err
 }
+
+// The record written for reflectValue
+func (enc *Encoder) record(reflectValue reflect.Value) ([]string, error) {
+	if !reflectValue.IsValid() {
+		return nil, fmt.Errorf("Can't marshal nil to csv")
+	}
+
+	switch {
+	case reflectValue.Type() == stringsType:
+		record := reflectValue.Interface().([]string)
+		// Don't sanitize the caller's slice
+		if enc.SanitizeFormulas != SanitizeNone || enc.ProtectNumbers {
+			record = append([]string(nil), record...)
+		}
+		return record, enc.sanitize(record, nil)
+	case isRow(reflectValue):
+		keys, _, cells, err := enc.rowColumns(reflectValue)
+		if err != nil {
+			return nil, err
+		}
+		record := make([]string, len(keys))
+		for i, key := range keys {
+			cell, ok := cells[key]
+			if !ok {
+				cell = enc.EmptyValue
+			}
+			record[i] = cell
+		}
+		return record, enc.sanitize(record, nil)
+	}
+
+	output, err := enc.marshal(reflectValue, false)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := enc.layoutFor(reflectValue.Type())
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.sanitize(output, l); err != nil {
+		return nil, err
+	}
+
+	record, err := l.arrange(output, enc.EmptyValue)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := enc.variantOf(reflectValue.Type()); ok {
+		return enc.variantRecord(record, v)
+	}
+	if l.extra == nil {
+		return record, nil
+	}
+	l.fixExtra(reflectValue)
+	if l.extraKeys == nil {
+		l.extraKeys = []string{}
+	}
+	extra, err := l.extraCells(reflectValue, enc.EmptyValue)
+	if err == nil {
+		err = enc.sanitize(extra, nil)
+	}
+	if err != nil || l.extraAt == nil {
+		return append(record, extra...), err
+	}
+	for i, position := range l.extraAt {
+		record[position] = extra[i]
+	}
+	return record, nil
+}
+
+// Header returns the column names Encode writes for i, nested
+// structs are named with dotted paths (person.name)
+func (enc *Encoder) Header(i interface{}) ([]string, error) {
+	reflectValue := reflect.ValueOf(i)
+	if !reflectValue.IsValid() {
+		return nil, fmt.Errorf("Can't derive a csv header from nil")
+	}
+	if isRow(reflectValue) {
+		_, header, _, err := enc.rowColumns(reflectValue)
+		return header, err
+	}
+	if _, ok := enc.variantOf(reflectValue.Type()); ok {
+		u, err := enc.unionLayout()
+		if err != nil {
+			return nil, err
+		}
+		return append([]string(nil), u.header...), nil
+	}
+	l, err := enc.layoutFor(reflectValue.Type())
+	if err != nil {
+		return nil, err
+	}
+	l.fixExtra(reflectValue)
+	if l.extraAt != nil {
+		return append([]string(nil), l.header...), nil
+	}
+	return append(append([]string(nil), l.header...), l.extraKeys...), nil
+}
+
+// EncodeHeader writes the header for i, typically before the first Encode
+func (enc *Encoder) EncodeHeader(i interface{}) error {
+	if enc.err != nil {
+		return enc.err
+	}
+
+	header, err := enc.Header(i)
+	if err == nil {
+		err = enc.sanitize(header, nil)
+	}
+	if err != nil {
+		enc.err = err
+		return enc.err
+	}
+
+	// The header can't grow once written, so it fixes the extra
+	// columns even when i has none
+	if l, ok := enc.layouts[reflect.TypeOf(i)]; ok && l.extra != nil && l.extraKeys == nil {
+		l.extraKeys, l.extraHeader = []string{}, true
+	}
+
+	enc.wroteHeader = true
+	return enc.write(header, nil)
+}
+
+// A column of an encoded record
+type column struct {
+	name string
+	typ  reflect.Type
+	// The struct field the column was derived from, if any
+	field reflect.StructField
+	tag   tagOptions
+	// Index of the cell marshal produces for this column
+	cell int
+	// Written as NilValue when it, or a parent struct, is nil
+	nullable bool
+}
+
+var (
+	getterType        = reflect.TypeOf((*Getter)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// The columns marshal produces for a value of type t, this mirrors
+// marshal so the header lines up with the encoded records.
+// Columns are returned in written order, see csv:",order=1"
+func (enc *Encoder) columns(t reflect.Type, name string) ([]column, error) {
+	if t.Kind() == reflect.Ptr {
+		columns, err := enc.columns(t.Elem(), name)
+		for i := range columns {
+			columns[i].nullable = true
+		}
+		return columns, err
+	}
+
+	ptr := reflect.PtrTo(t)
+	if t.Kind() != reflect.Struct || ptr.Implements(getterType) || ptr.Implements(textMarshalerType) {
+		return []column{{name: name, typ: t, nullable: t.Kind() == reflect.Interface}}, nil
+	}
+
+	// The columns of each field, kept together when reordering
+	type group struct {
+		columns []column
+		order   int
+		ordered bool
+	}
+	groups := []group{}
+	cells := 0
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if tag.skip(field) || tag.capture() {
+			continue
+		}
+
+		fieldName := name
+		if !tag.inline(field) {
+			fieldName = joinName(name, tag.columnName(field, enc.NameMapper))
+		}
+		fieldColumns, err := enc.columns(field.Type, fieldName)
+		if err != nil {
+			return nil, err
+		}
+		if len(fieldColumns) == 1 && fieldColumns[0].field.Name == "" {
+			fieldColumns[0].field = field
+			fieldColumns[0].tag = tag
+		}
+		for j := range fieldColumns {
+			fieldColumns[j].cell += cells
+		}
+		cells += len(fieldColumns)
+
+		order, ordered, err := tag.intValue(field, "order")
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group{fieldColumns, order, ordered})
+	}
+
+	// Ordered fields come first, the rest follow in struct order
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].ordered != groups[j].ordered {
+			return groups[i].ordered
+		}
+		return groups[i].order < groups[j].order
+	})
+
+	columns := make([]column, 0, cells)
+	for _, g := range groups {
+		columns = append(columns, g.columns...)
+	}
+	return columns, nil
+}
+
+// Columns chooses, orders and renames the columns Encode writes.
+// Each is a dotted path as named by Header, optionally
+// followed by = and the name to write, "person.name=Name".
+// Other names are columns of the csv:",extra" field, only
+// the extra columns named are written
+func (enc *Encoder) Columns(columns []string) {
+	enc.projection = columns
+	enc.layouts = nil
+	enc.union = nil
+}
+
+// Where the cells marshal produces for a type are written
+type layout struct {
+	header []string
+	// The columns indexed by cell
+	columns []column
+	// How many cells marshal produces
+	cells int
+	// The cell written at each record position, -1 for gaps.
+	// nil when the cells are written as is
+	sources []int
+	// The csv:",extra" field, its columns are written after the
+	// others and fixed by the first value with any, or by Columns
+	extra     []int
+	extraKeys []string
+	// The record position of each extra column named by Columns
+	extraAt []int
+	// The extra columns were fixed by writing a header without them
+	extraHeader bool
+}
+
+func (enc *Encoder) layoutFor(t reflect.Type) (*layout, error) {
+	if l, ok := enc.layouts[t]; ok {
+		return l, nil
+	}
+
+	columns, err := enc.columns(t, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Positional columns, csv:",index=3" are placed first
+	// the rest fill the gaps
+	width := len(columns)
+	taken := map[int]bool{}
+	for _, column := range columns {
+		index, ok, err := column.tag.intValue(column.field, "index")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if taken[index] {
+			return nil, fmt.Errorf("struct field `%s`: index %d is used twice", column.field.Name, index)
+		}
+		taken[index] = true
+		if index >= width {
+			width = index + 1
+		}
+	}
+
+	l := &layout{
+		header:  make([]string, width),
+		columns: make([]column, len(columns)),
+		cells:   len(columns),
+		sources: make([]int, width),
+	}
+	for i := range l.sources {
+		l.sources[i] = -1
+	}
+	next := 0
+	for _, column := range columns {
+		position, ok, _ := column.tag.intValue(column.field, "index")
+		if !ok {
+			for taken[next] {
+				next++
+			}
+			taken[next] = true
+			position = next
+		}
+		l.header[position] = column.name
+		l.sources[position] = column.cell
+		l.columns[column.cell] = column
+	}
+
+	l.extra = extraField(t)
+	if enc.projection != nil {
+		positions := make(map[string]int, len(l.header))
+		for i, name := range l.header {
+			positions[normalizeName(name)] = i
+		}
+		header := make([]string, len(enc.projection))
+		sources := make([]int, len(enc.projection))
+		for i, projection := range enc.projection {
+			parts := strings.SplitN(projection, "=", 2)
+			header[i] = parts[len(parts)-1]
+			position, ok := positions[normalizeName(parts[0])]
+			switch {
+			case ok:
+				if len(parts) == 1 {
+					header[i] = l.header[position]
+				}
+				sources[i] = l.sources[position]
+			case l.extra != nil:
+				l.extraKeys = append(l.extraKeys, parts[0])
+				l.extraAt = append(l.extraAt, i)
+				sources[i] = -1
+			default:
+				return nil, fmt.Errorf("unknown column `%s`", parts[0])
+			}
+		}
+		l.header, l.sources = header, sources
+		if l.extra != nil && l.extraKeys == nil {
+			l.extraKeys, l.extraAt = []string{}, []int{}
+		}
+	}
+
+	if l.identity() {
+		l.sources = nil
+	}
+
+	if enc.layouts == nil {
+		enc.layouts = map[reflect.Type]*layout{}
+	}
+	enc.layouts[t] = l
+	return l, nil
+}
+
+func (l *layout) identity() bool {
+	if len(l.sources) != l.cells {
+		return false
+	}
+	for i, source := range l.sources {
+		if source != i {
+			return false
+		}
+	}
+	return true
+}
+
+// Gather the cells into their record positions, gaps are empty
+func (l *layout) arrange(cells []string, empty string) ([]string, error) {
+	if l.sources == nil {
+		return cells, nil
+	}
+	// Getters may produce any number of cells,
+	// in which case there is nothing to line up
+	if len(cells) != l.cells {
+		return nil, fmt.Errorf("can't arrange %d cells into %d columns", len(cells), l.cells)
+	}
+	record := make([]string, len(l.sources))
+	for i, source := range l.sources {
+		if source < 0 {
+			record[i] = empty
+			continue
+		}
+		record[i] = cells[source]
+	}
+	return record, nil
+}
+
+var stringsType = reflect.TypeOf([]string(nil))
+
+// Maps with string keys are written as a whole record
+// unless they encode themselves
+func isRow(v reflect.Value) bool {
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	ptr := reflect.PtrTo(v.Type())
+	return !ptr.Implements(getterType) && !ptr.Implements(textMarshalerType)
+}
+
+// The keys, header and cells of a map row. Rows are written in a fixed
+// column order, chosen by Columns or else the sorted keys of the first row
+func (enc *Encoder) rowColumns(v reflect.Value) (keys, header []string, cells map[string]string, err error) {
+	cells = map[string]string{}
+	if err := enc.flatten(v, "", cells); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if enc.projection != nil {
+		for _, projection := range enc.projection {
+			parts := strings.SplitN(projection, "=", 2)
+			keys = append(keys, parts[0])
+			header = append(header, parts[len(parts)-1])
+		}
+		return keys, header, cells, nil
+	}
+
+	if enc.rowKeys == nil {
+		for key := range cells {
+			enc.rowKeys = append(enc.rowKeys, key)
+		}
+		sort.Strings(enc.rowKeys)
+	}
+	for key := range cells {
+		i := sort.SearchStrings(enc.rowKeys, key)
+		if i == len(enc.rowKeys) || enc.rowKeys[i] != key {
+			return nil, nil, nil, fmt.Errorf("unknown column `%s`, columns are fixed by the first row", key)
+		}
+	}
+	return enc.rowKeys, enc.rowKeys, cells, nil
+}
+
+// Flatten a map row into cells, nested maps become dotted keys
+func (enc *Encoder) flatten(v reflect.Value, prefix string, cells map[string]string) error {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	for _, key := range v.MapKeys() {
+		name := joinName(prefix, key.String())
+		value := v.MapIndex(key)
+		if value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+		if isRow(value) {
+			if err := enc.flatten(value, name, cells); err != nil {
+				return err
+			}
+			continue
+		}
+		output, err := enc.marshal(value, false)
+		if err != nil {
+			return fmt.Errorf("map value `%s`: %s", name, err.Error())
+		}
+		cells[name] = strings.Join(output, ",")
+	}
+	return nil
+}
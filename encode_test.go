@@ -119,6 +119,26 @@ var _ = Describe("CSV Encoding", func() {
 		Ω(b.String()).Should(Equal(expectedOutput))
 	})
 
+	It("should encode unsigned ints", func() {
+		input := struct {
+			Small uint8
+			Big   uint64
+		}{255, 18446744073709551615}
+		err = encoder.Encode(input)
+		Ω(err).Should(BeNil())
+		Ω(b.String()).Should(Equal("255,18446744073709551615\n"))
+	})
+
+	It("should encode time with a format tag", func() {
+		input := struct {
+			Born time.Time  `csv:",format=02/01/2006"`
+			Died *time.Time `csv:",format=02/01/2006"`
+		}{Born: time.Date(1990, 5, 15, 0, 0, 0, 0, time.UTC)}
+		err = encoder.Encode(input)
+		Ω(err).Should(BeNil())
+		Ω(b.String()).Should(Equal("15/05/1990,NULL\n"))
+	})
+
 	It("should encode nil structs", func() {
 		type ChildStruct struct {
 			Names []string
@@ -180,6 +200,139 @@ var _ = Describe("CSV Encoding", func() {
 		Ω(b.String()).Should(Equal(expectedOutput))
 	})
 
+	Context("Header", func() {
+		type ChildStruct struct {
+			FirstName string
+		}
+		type AnonymousStruct struct {
+			UserID int `csv:"uid"`
+		}
+		input := struct {
+			AnonymousStruct
+			Child   ChildStruct
+			Skipped string `csv:"-"`
+		}{}
+
+		It("should name nested columns with dotted paths", func() {
+			header, err := encoder.Header(input)
+			Ω(err).Should(BeNil())
+			Ω(header).Should(Equal([]string{"uid", "child.firstname"}))
+		})
+
+		It("should split field names into words", func() {
+			Ω(csvencoding.SnakeCase("HTTPServerID")).Should(Equal("http_server_id"))
+			Ω(csvencoding.KebabCase("UserID")).Should(Equal("user-id"))
+			Ω(csvencoding.CamelCase("UserID")).Should(Equal("userId"))
+			Ω(csvencoding.LowerCase("UserID")).Should(Equal("userid"))
+		})
+
+		It("should derive names with a NameMapper", func() {
+			encoder.NameMapper = csvencoding.SnakeCase
+			err = encoder.EncodeHeader(input)
+			Ω(err).Should(BeNil())
+			Ω(b.String()).Should(Equal("uid,child.first_name\n"))
+		})
+	})
+
+	It("should place positional columns", func() {
+		input := struct {
+			Name    string `csv:",index=2"`
+			Age     int    `csv:",index=0"`
+			Country string
+		}{"vin", 50, "usa"}
+		header, err := encoder.Header(input)
+		Ω(err).Should(BeNil())
+		Ω(header).Should(Equal([]string{"age", "country", "name"}))
+		err = encoder.Encode(input)
+		Ω(err).Should(BeNil())
+		Ω(b.String()).Should(Equal("50,usa,vin\n"))
+	})
+
+	It("should fill gaps between positional columns", func() {
+		input := struct {
+			Name string `csv:",index=2"`
+		}{"vin"}
+		err = encoder.Encode(input)
+		Ω(err).Should(BeNil())
+		Ω(b.String()).Should(Equal(",,vin\n"))
+	})
+
+	Context("Column selection", func() {
+		type ChildStruct struct {
+			Name string
+			Age  int
+		}
+		input := struct {
+			ID    int
+			Child ChildStruct
+			Notes string
+		}{7, ChildStruct{"vin", 50}, "bald"}
+
+		It("should choose, reorder and rename columns", func() {
+			encoder.Columns([]string{"child.name=Name", "id"})
+			header, err := encoder.Header(input)
+			Ω(err).Should(BeNil())
+			Ω(header).Should(Equal([]string{"Name", "id"}))
+			err = encoder.Encode(input)
+			Ω(err).Should(BeNil())
+			Ω(b.String()).Should(Equal("vin,7\n"))
+		})
+
+		It("should error on unknown columns", func() {
+			encoder.Columns([]string{"child.height"})
+			err = encoder.Encode(input)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should order columns by tag", func() {
+			input := struct {
+				ID    int `csv:",order=2"`
+				Child ChildStruct
+				Notes string `csv:",order=1"`
+			}{7, ChildStruct{"vin", 50}, "bald"}
+			header, err := encoder.Header(input)
+			Ω(err).Should(BeNil())
+			Ω(header).Should(Equal([]string{"notes", "id", "child.name", "child.age"}))
+			err = encoder.Encode(input)
+			Ω(err).Should(BeNil())
+			Ω(b.String()).Should(Equal("bald,7,vin,50\n"))
+		})
+	})
+
+	Context("Dynamic rows", func() {
+		It("should encode slices as records", func() {
+			err = encoder.Encode([]string{"vin", "diesel"})
+			Ω(err).Should(BeNil())
+			Ω(b.String()).Should(Equal("vin,diesel\n"))
+		})
+
+		It("should fix the column order from the first map", func() {
+			err = encoder.EncodeHeader(map[string]string{"name": "vin", "age": "50"})
+			Ω(err).Should(BeNil())
+			err = encoder.Encode(map[string]string{"name": "vin", "age": "50"})
+			Ω(err).Should(BeNil())
+			err = encoder.Encode(map[string]string{"name": "dom"})
+			Ω(err).Should(BeNil())
+			Ω(b.String()).Should(Equal("age,name\n50,vin\n,dom\n"))
+			err = encoder.Encode(map[string]string{"height": "1.78"})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should flatten nested maps", func() {
+			encoder.Columns([]string{"name", "person.age=age"})
+			input := map[string]interface{}{
+				"name":   "vin",
+				"person": map[string]interface{}{"age": nil},
+			}
+			header, err := encoder.Header(input)
+			Ω(err).Should(BeNil())
+			Ω(header).Should(Equal([]string{"name", "age"}))
+			err = encoder.Encode(input)
+			Ω(err).Should(BeNil())
+			Ω(b.String()).Should(Equal("vin,NULL\n"))
+		})
+	})
+
 	It("should populate exported embedded annonymous structs", func() {
 		type AnonymousStruct struct {
 			Name string
@@ -0,0 +1,178 @@
+package csvencoding
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A NameMapper derives a column name from a struct field name,
+// it is used for fields whose csv tag doesn't name a column
+type NameMapper func(fieldName string) string
+
+var (
+	// UserID -> userid, the historic behaviour
+	LowerCase NameMapper = strings.ToLower
+	// UserID -> user_id
+	SnakeCase NameMapper = func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "_"))
+	}
+	// UserID -> user-id
+	KebabCase NameMapper = func(s string) string {
+		return strings.ToLower(strings.Join(splitWords(s), "-"))
+	}
+	// UserID -> userId
+	CamelCase NameMapper = func(s string) string {
+		words := splitWords(s)
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 && word != "" {
+				runes := []rune(word)
+				runes[0] = unicode.ToUpper(runes[0])
+				word = string(runes)
+			}
+			words[i] = word
+		}
+		return strings.Join(words, "")
+	}
+)
+
+// The NameMapper used by NewDecoder and NewEncoder
+var DefaultNameMapper = LowerCase
+
+// Split a go identifier into its words, acronyms are kept together
+// so HTTPServerID becomes HTTP, Server, ID
+func splitWords(s string) []string {
+	var words []string
+	runes := []rune(s)
+	start := 0
+	for i, r := range runes {
+		if r == '_' || r == '-' || unicode.IsSpace(r) {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i > start && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// Header cells and field names are compared ignoring case, whitespace,
+// _ and -, so "User ID", "user_id" and "UserId" are the same column
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name))
+}
+
+// A parsed csv struct tag, the column name followed by
+// flags (omitEmpty) and key=value options (alias=uid|UserId)
+type tagOptions struct {
+	name    string
+	options []string
+}
+
+func parseTag(field reflect.StructField) tagOptions {
+	parts := strings.Split(field.Tag.Get("csv"), ",")
+	return tagOptions{name: parts[0], options: parts[1:]}
+}
+
+// Is the flag present, ie csv:",omitEmpty"
+func (t tagOptions) has(flag string) bool {
+	for _, option := range t.options {
+		if option == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// The value of a key=value option
+func (t tagOptions) value(key string) (string, bool) {
+	for _, option := range t.options {
+		if strings.HasPrefix(option, key+"=") {
+			return option[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// Alternative column names, csv:"user_id,alias=uid|UserId"
+func (t tagOptions) aliases() []string {
+	value, ok := t.value("alias")
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, "|")
+}
+
+// An integer option, ok is false when the option isn't present
+func (t tagOptions) intValue(field reflect.StructField, key string) (n int, ok bool, err error) {
+	value, ok := t.value(key)
+	if !ok {
+		return 0, false, nil
+	}
+	n, err = strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, true, fmt.Errorf("struct field `%s`: invalid %s `%s`", field.Name, key, value)
+	}
+	return n, true, nil
+}
+
+// csv:"-" and unexported fields are never read or written,
+// PkgPath == "" and !Anonymous for unexported fields
+func (t tagOptions) skip(field reflect.StructField) bool {
+	return t.name == "-" || (field.PkgPath != "" && !field.Anonymous)
+}
+
+// Fields capturing the record rather than a column, csv:",rest",
+// csv:",extra", csv:",line" and csv:",raw". The Decoder fills
+// them, the Encoder only writes the extra columns
+func (t tagOptions) capture() bool {
+	return t.has("rest") || t.has("extra") || t.has("line") || t.has("raw")
+}
+
+// Embedded structs without a column name have their fields promoted
+func (t tagOptions) inline(field reflect.StructField) bool {
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	return field.Anonymous && t.name == "" && fieldType.Kind() == reflect.Struct
+}
+
+// The column name of a field, from its tag or the NameMapper
+func (t tagOptions) columnName(field reflect.StructField, mapper NameMapper) string {
+	if t.name != "" {
+		return t.name
+	}
+	if mapper == nil {
+		mapper = DefaultNameMapper
+	}
+	return mapper(field.Name)
+}
+
+// Join a parent column name and child field name into a dotted path
+func joinName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
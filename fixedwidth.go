@@ -0,0 +1,267 @@
+package csvencoding
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// A fixedField is a struct field laid out at a position of a
+// fixed-width line, csv:"amount,start=10,width=12,align=right,pad=0"
+type fixedField struct {
+	name  string
+	index []int
+	tag   tagOptions
+	// Offset and length in characters
+	start, width int
+	right        bool
+	pad          rune
+}
+
+// The fields of struct types, shared by every fixed-width
+// decoder and encoder as they don't depend on configuration
+var fixedLayouts sync.Map
+
+func fixedLayoutFor(t reflect.Type) ([]fixedField, error) {
+	if fields, ok := fixedLayouts.Load(t); ok {
+		return fields.([]fixedField), nil
+	}
+	var fields []fixedField
+	end := 0
+	if err := fixedFields(t, nil, &fields, &end); err != nil {
+		return nil, err
+	}
+	for i, a := range fields {
+		for _, b := range fields[:i] {
+			if a.start < b.start+b.width && b.start < a.start+a.width {
+				return nil, fmt.Errorf("struct field `%s` overlaps `%s`", a.name, b.name)
+			}
+		}
+	}
+	fixedLayouts.Store(t, fields)
+	return fields, nil
+}
+
+// Walk the fields of t, fields without a start follow the one before
+func fixedFields(t reflect.Type, index []int, fields *[]fixedField, end *int) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseTag(field)
+		if tag.skip(field) || tag.capture() {
+			continue
+		}
+		fieldIndex := append(index[:len(index):len(index)], i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if tag.inline(field) || isContainer(fieldType) {
+			if err := fixedFields(fieldType, fieldIndex, fields, end); err != nil {
+				return err
+			}
+			continue
+		}
+
+		f := fixedField{name: field.Name, index: fieldIndex, tag: tag, start: *end, pad: ' '}
+		start, ok, err := tag.intValue(field, "start")
+		if err != nil {
+			return err
+		}
+		if ok {
+			f.start = start
+		}
+		width, ok, err := tag.intValue(field, "width")
+		if err != nil {
+			return err
+		}
+		if !ok || width == 0 {
+			return fmt.Errorf("struct field `%s`: fixed-width fields need a width", field.Name)
+		}
+		f.width = width
+
+		switch align, _ := tag.value("align"); align {
+		case "", "left":
+		case "right":
+			f.right = true
+		default:
+			return fmt.Errorf("struct field `%s`: invalid align `%s`", field.Name, align)
+		}
+		if pad, ok := tag.value("pad"); ok {
+			if utf8.RuneCountInString(pad) != 1 {
+				return fmt.Errorf("struct field `%s`: invalid pad `%s`", field.Name, pad)
+			}
+			f.pad, _ = utf8.DecodeRuneInString(pad)
+		}
+
+		*fields = append(*fields, f)
+		*end = f.start + f.width
+	}
+	return nil
+}
+
+// A FixedWidthDecoder reads lines whose cells are at fixed positions,
+// fields are placed with the start, width, align and pad tag options
+// and converted like those of a Decoder
+type FixedWidthDecoder struct {
+	r   *bufio.Reader
+	err error
+	// A cell value that translates to the types zero value
+	EmptyValue string
+	// A cell value that translates to null
+	NilValue string
+
+	// The line last read
+	line int
+}
+
+func NewFixedWidthDecoder(r io.Reader) *FixedWidthDecoder {
+	return &FixedWidthDecoder{
+		r:          bufio.NewReader(r),
+		EmptyValue: DefaultEmptyValue,
+		NilValue:   DefaultNilValue,
+	}
+}
+
+// Decode the next line into the struct pointed to by i,
+// blank lines are skipped and io.EOF returned at the end
+func (fd *FixedWidthDecoder) Decode(i interface{}) error {
+	if fd.err != nil {
+		return fd.err
+	}
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Can't unmarshal fixed-width line into %T", i)
+	}
+	fields, err := fixedLayoutFor(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	var line string
+	for line == "" {
+		if fd.err != nil {
+			return fd.err
+		}
+		line, fd.err = fd.r.ReadString('\n')
+		// The last line may not end in a newline
+		if fd.err == io.EOF && line != "" {
+			fd.err = nil
+		}
+		fd.line++
+		line = strings.TrimRight(line, "\r\n")
+	}
+
+	// Decoder only for its conversion of cells
+	dec := &Decoder{EmptyValue: fd.EmptyValue, NilValue: fd.NilValue}
+	runes := []rune(line)
+	for _, f := range fields {
+		cell := ""
+		if f.start < len(runes) {
+			cell = string(runes[f.start:min(f.start+f.width, len(runes))])
+		}
+		if f.right {
+			cell = strings.TrimLeft(cell, string(f.pad))
+		} else {
+			cell = strings.TrimRight(cell, string(f.pad))
+		}
+		format, _ := f.tag.value("format")
+		if err := dec.readStringTo(fieldByIndex(v.Elem(), f.index), cell, format); err != nil {
+			fd.err = &RecordError{Line: fd.line, Err: fmt.Errorf("struct field `%s`: %s", f.name, err)}
+			return fd.err
+		}
+	}
+	return nil
+}
+
+// A FixedWidthEncoder writes structs as fixed-width lines,
+// the counterpart of FixedWidthDecoder
+type FixedWidthEncoder struct {
+	w   io.Writer
+	err error
+	// A cell value to be used when omitempty is specified on a reflectValue
+	EmptyValue string
+	// A cell value to be used for nil values
+	NilValue string
+	// Terminate lines with \r\n instead of \n
+	UseCRLF bool
+}
+
+func NewFixedWidthEncoder(w io.Writer) *FixedWidthEncoder {
+	return &FixedWidthEncoder{
+		w:          w,
+		EmptyValue: DefaultEmptyValue,
+		NilValue:   DefaultNilValue,
+	}
+}
+
+// Encode writes the struct i as a line, cells wider than
+// their field are an error rather than being cut short
+func (fe *FixedWidthEncoder) Encode(i interface{}) error {
+	if fe.err != nil {
+		return fe.err
+	}
+	v := reflect.Indirect(reflect.ValueOf(i))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("Can't marshal %T to a fixed-width line", i)
+	}
+	fields, err := fixedLayoutFor(v.Type())
+	if err != nil {
+		return err
+	}
+
+	// Encoder only for its conversion of cells
+	enc := &Encoder{EmptyValue: fe.EmptyValue, NilValue: fe.NilValue}
+	var line []rune
+	for _, f := range fields {
+		cell := fe.NilValue
+		if fieldValue, ok := fieldAt(v, f.index); ok {
+			cells, err := enc.marshalField(fieldValue, f.tag)
+			if err != nil {
+				return fmt.Errorf("struct field `%s`: %s", f.name, err)
+			}
+			cell = strings.Join(cells, ",")
+		}
+
+		runes := []rune(cell)
+		if len(runes) > f.width {
+			return fmt.Errorf("struct field `%s`: `%s` is wider than %d", f.name, cell, f.width)
+		}
+		padding := []rune(strings.Repeat(string(f.pad), f.width-len(runes)))
+		if f.right {
+			runes = append(padding, runes...)
+		} else {
+			runes = append(runes, padding...)
+		}
+
+		for len(line) < f.start+f.width {
+			line = append(line, ' ')
+		}
+		copy(line[f.start:], runes)
+	}
+
+	terminator := "\n"
+	if fe.UseCRLF {
+		terminator = "\r\n"
+	}
+	_, fe.err = io.WriteString(fe.w, string(line)+terminator)
+	return fe.err
+}
+
+// The field at index, ok is false when a struct
+// pointer along the way is nil
+func fieldAt(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,79 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type payment struct {
+	Account string    `csv:"account,width=8"`
+	Date    time.Time `csv:"date,start=10,width=8,format=20060102"`
+	Amount  int       `csv:"amount,width=12,align=right,pad=0"`
+	Memo    *string   `csv:"memo,width=10"`
+}
+
+var _ = Describe("Fixed width", func() {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	memo := "rent"
+	line := "ACC-1     20240301000000012500rent      \n"
+
+	It("should decode lines", func() {
+		decoder := csvencoding.NewFixedWidthDecoder(strings.NewReader(line + "\n" + line))
+		for i := 0; i < 2; i++ {
+			var output payment
+			Ω(decoder.Decode(&output)).Should(Succeed())
+			Ω(output).Should(Equal(payment{"ACC-1", date, 12500, &memo}))
+		}
+		var output payment
+		Ω(decoder.Decode(&output)).Should(Equal(io.EOF))
+	})
+
+	It("should encode lines", func() {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewFixedWidthEncoder(buffer)
+		encoder.NilValue = ""
+		Ω(encoder.Encode(payment{"ACC-1", date, 12500, &memo})).Should(Succeed())
+		Ω(encoder.Encode(payment{"ACC-2", date, 7, nil})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal(line + "ACC-2     20240301000000000007          \n"))
+	})
+
+	It("should read the same struct from csv", func() {
+		var output payment
+		Ω(decode("account,date,amount,memo\nACC-1,20240301,12500,rent\n", &output)).Should(Succeed())
+		Ω(output).Should(Equal(payment{"ACC-1", date, 12500, &memo}))
+	})
+
+	It("should report the line of bad cells", func() {
+		decoder := csvencoding.NewFixedWidthDecoder(strings.NewReader(line + "ACC-1     2024030100000000x500\n"))
+		var output payment
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		err := decoder.Decode(&output)
+		var recordErr *csvencoding.RecordError
+		Ω(errors.As(err, &recordErr)).Should(BeTrue())
+		Ω(recordErr.Line).Should(Equal(2))
+		Ω(err.Error()).Should(HavePrefix("line 2: struct field `Amount`"))
+	})
+
+	It("should reject cells wider than their field", func() {
+		encoder := csvencoding.NewFixedWidthEncoder(&bytes.Buffer{})
+		err := encoder.Encode(payment{Account: "ACCOUNT-12"})
+		Ω(err).Should(MatchError("struct field `Account`: `ACCOUNT-12` is wider than 8"))
+	})
+
+	It("should reject overlapping fields", func() {
+		type overlapping struct {
+			A string `csv:",width=4"`
+			B string `csv:",start=2,width=4"`
+		}
+		var output overlapping
+		err := csvencoding.NewFixedWidthDecoder(strings.NewReader("abcdef\n")).Decode(&output)
+		Ω(err).Should(MatchError("struct field `B` overlaps `A`"))
+	})
+})
@@ -0,0 +1,93 @@
+package csvencoding
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits bound what a Decoder accepts from hostile input, zero is
+// unlimited. NewReaderDecoder enforces them while reading so only
+// MaxRecordBytes are ever buffered. A csv.Reader, for NewDecoder,
+// returns whole records which are only checked once read, so an
+// enormous record is in memory before it's refused
+type Limits struct {
+	// The bytes a record spans, for a csv.Reader its cells and delimiters
+	MaxRecordBytes int
+	// Cells in a record, the header included
+	MaxFields int
+	// The bytes of a single cell
+	MaxCellBytes int
+	// Elements of a cell split into a slice field, csv:"tags" 1,2,3
+	MaxSliceElements int
+	// Levels of a dotted header name decoded into
+	// nested maps, a.b.c has 3
+	MaxDepth int
+}
+
+// The Limits a Decoder starts with. The header is read by the first
+// Decode, so the Limits of a Decoder can be changed before then
+var DefaultLimits = Limits{
+	MaxRecordBytes:   16 << 20,
+	MaxFields:        10000,
+	MaxCellBytes:     4 << 20,
+	MaxSliceElements: 10000,
+	MaxDepth:         32,
+}
+
+// A LimitError is input exceeding one of the Decoder's Limits,
+// records are wrapped in a RecordError with their line
+type LimitError struct {
+	// The field of Limits, MaxFields
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("exceeds %s of %d", e.Limit, e.Max)
+}
+
+// Is n over the limit max
+func exceeds(n, max int) bool {
+	return max > 0 && n > max
+}
+
+// Check a record read whole
+func (l *Limits) checkRecord(record []string) error {
+	if exceeds(len(record), l.MaxFields) {
+		return &LimitError{"MaxFields", l.MaxFields}
+	}
+	if l.MaxRecordBytes == 0 && l.MaxCellBytes == 0 {
+		return nil
+	}
+	size := len(record)
+	for _, cell := range record {
+		if exceeds(len(cell), l.MaxCellBytes) {
+			return &LimitError{"MaxCellBytes", l.MaxCellBytes}
+		}
+		size += len(cell)
+	}
+	// Delimiters between the cells
+	if exceeds(size-1, l.MaxRecordBytes) {
+		return &LimitError{"MaxRecordBytes", l.MaxRecordBytes}
+	}
+	return nil
+}
+
+// Check the cell of a slice field before splitting it
+func (l *Limits) checkSlice(value string) error {
+	if exceeds(strings.Count(value, ",")+1, l.MaxSliceElements) {
+		return &LimitError{"MaxSliceElements", l.MaxSliceElements}
+	}
+	return nil
+}
+
+// The most levels of the header's dotted names
+func headerDepth(header []string) int {
+	depth := 0
+	for _, name := range header {
+		if d := strings.Count(name, ".") + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
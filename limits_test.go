@@ -0,0 +1,109 @@
+package csvencoding_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limits", func() {
+	// The limit exceeded by err
+	limitOf := func(err error) string {
+		var limitErr *csvencoding.LimitError
+		Ω(errors.As(err, &limitErr)).Should(BeTrue())
+		return limitErr.Limit
+	}
+	// The line of the record exceeding it
+	lineOf := func(err error) int {
+		var recordErr *csvencoding.RecordError
+		Ω(errors.As(err, &recordErr)).Should(BeTrue())
+		return recordErr.Line
+	}
+
+	It("should stop reading an enormous quoted cell", func() {
+		// The closing quote never comes
+		input := "name,bio\nbob,\"" + strings.Repeat("lorem ipsum\n", 100000)
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		decoder.Limits.MaxRecordBytes = 1 << 10
+		var output []string
+		err := decoder.Decode(&output)
+		Ω(limitOf(err)).Should(Equal("MaxRecordBytes"))
+		Ω(err).Should(MatchError("line 2: exceeds MaxRecordBytes of 1024"))
+		Ω(decoder.Decode(&output)).Should(Equal(err))
+	})
+
+	It("should stop reading an enormous line", func() {
+		input := "name\n" + strings.Repeat("x", 1<<20) + "\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		decoder.Limits.MaxCellBytes = 100
+		decoder.Limits.MaxRecordBytes = 1 << 12
+		var output []string
+		Ω(limitOf(decoder.Decode(&output))).Should(Equal("MaxRecordBytes"))
+	})
+
+	It("should check the header", func() {
+		input := "a,b,c,d\n1,2,3,4\n"
+		var output []string
+		for _, decoder := range []*csvencoding.Decoder{
+			csvencoding.NewDecoder(reader(input)),
+			csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{}),
+		} {
+			decoder.Limits.MaxFields = 3
+			Ω(decoder.Header()).Should(BeEmpty())
+			err := decoder.Decode(&output)
+			Ω(limitOf(err)).Should(Equal("MaxFields"))
+			Ω(lineOf(err)).Should(Equal(1))
+		}
+	})
+
+	It("should limit by default", func() {
+		input := strings.Repeat("a,", csvencoding.DefaultLimits.MaxFields) + "a\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var output []string
+		Ω(limitOf(decoder.Decode(&output))).Should(Equal("MaxFields"))
+
+		decoder = csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		decoder.Limits = csvencoding.Limits{}
+		Ω(decoder.Header()).Should(HaveLen(csvencoding.DefaultLimits.MaxFields + 1))
+	})
+
+	It("should check cells", func() {
+		input := "name,bio\nbob,short\nsue,\"much too long\"\n"
+		for _, decoder := range []*csvencoding.Decoder{
+			csvencoding.NewDecoder(reader(input)),
+			csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{}),
+		} {
+			decoder.Limits.MaxCellBytes = 8
+			var output []string
+			Ω(decoder.Decode(&output)).Should(Succeed())
+			err := decoder.Decode(&output)
+			Ω(limitOf(err)).Should(Equal("MaxCellBytes"))
+			Ω(lineOf(err)).Should(Equal(3))
+		}
+	})
+
+	It("should check slices", func() {
+		type post struct {
+			Tags []string
+		}
+		decoder := csvencoding.NewDecoder(reader("tags\n\"a,b,c,d\"\n"))
+		decoder.Limits.MaxSliceElements = 3
+		var output post
+		Ω(limitOf(decoder.Decode(&output))).Should(Equal("MaxSliceElements"))
+	})
+
+	It("should check the depth of dotted names", func() {
+		input := "id,a.b.c.d\n1,x\n"
+		decoder := csvencoding.NewDecoder(reader(input))
+		decoder.Limits.MaxDepth = 3
+		output := csvencoding.CellValues{}
+		Ω(limitOf(decoder.Decode(&output))).Should(Equal("MaxDepth"))
+
+		decoder = csvencoding.NewDecoder(reader(input))
+		decoder.Limits.MaxDepth = 4
+		Ω(decoder.Decode(&output)).Should(Succeed())
+	})
+})
@@ -0,0 +1,158 @@
+package csvencoding
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A MappingReport previews how the header maps onto a struct
+type MappingReport struct {
+	// Columns bound to a field
+	Matched []ColumnMapping
+	// Columns no field reads, a csv:",extra" field collects them
+	Unmapped []string
+	// Fields tagged csv:",required" without a column, by go path
+	Missing []string
+	// Unmapped columns that are likely typos of a field's name
+	Suggestions []Suggestion
+}
+
+// A column and the field it decodes into
+type ColumnMapping struct {
+	Column string
+	// The position of the column in the header
+	Index int
+	// The go path of the field, Address.City
+	Field string
+}
+
+// An unmapped column close to the name of a field without one
+type Suggestion struct {
+	Column string
+	// The go path of the field
+	Field string
+	// The column name the field expects
+	Expected string
+	// Edits between the normalized names
+	Distance int
+}
+
+// Plan reports how the header maps onto the struct v, or the struct
+// it points to, under the Decoder's current settings. Only the
+// header is read, if it hasn't been yet
+func (dec *Decoder) Plan(v interface{}) (*MappingReport, error) {
+	dec.start()
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Can't plan csv decoding into %T", v)
+	}
+	p, err := dec.planFor(t)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MappingReport{}
+	for _, b := range p.bindings {
+		columns := b.collect
+		if columns == nil {
+			columns = []int{b.column}
+		}
+		for _, column := range columns {
+			name := ""
+			if column < len(dec.header) {
+				name = dec.header[column]
+			}
+			report.Matched = append(report.Matched, ColumnMapping{name, column, fieldPath(t, b.index)})
+		}
+	}
+	for _, column := range p.unbound {
+		report.Unmapped = append(report.Unmapped, dec.header[column])
+	}
+	for _, field := range p.unmatched {
+		if field.required {
+			report.Missing = append(report.Missing, fieldPath(t, field.index))
+		}
+	}
+
+	// The closest field to each unmapped column
+	for _, column := range p.unbound {
+		name := normalizeName(dec.header[column])
+		var best *Suggestion
+		for _, field := range p.unmatched {
+			for _, key := range field.keys {
+				distance := editDistance(name, key)
+				if !likelyTypo(name, key, distance) || (best != nil && best.Distance <= distance) {
+					continue
+				}
+				best = &Suggestion{dec.header[column], fieldPath(t, field.index), key, distance}
+			}
+		}
+		if best != nil {
+			report.Suggestions = append(report.Suggestions, *best)
+		}
+	}
+	return report, nil
+}
+
+// A field of a plan without a column
+type unmatchedField struct {
+	index []int
+	// The normalized column names it would read
+	keys     []string
+	required bool
+}
+
+// The go path of the field at index, Address.City
+func fieldPath(t reflect.Type, index []int) string {
+	names := make([]string, len(index))
+	for i, x := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		field := t.Field(x)
+		names[i] = field.Name
+		t = field.Type
+	}
+	return strings.Join(names, ".")
+}
+
+// Close enough to be a typo, a third of the name at most
+func likelyTypo(a, b string, distance int) bool {
+	n := len(b)
+	if len(a) > n {
+		n = len(a)
+	}
+	return distance > 0 && distance <= 2 && distance*3 <= n
+}
+
+// The edit distance between a and b, counting insertions,
+// deletions, substitutions and swaps of adjacent letters
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// The rows of the last two letters of a and the current one
+	before := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], before[j-2]+1)
+			}
+		}
+		before, previous, current = previous, current, before
+	}
+	return previous[len(rb)]
+}
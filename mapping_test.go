@@ -0,0 +1,50 @@
+package csvencoding_test
+
+import (
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mapping report", func() {
+	type address struct {
+		City    string
+		Country string `csv:",required"`
+	}
+	type customer struct {
+		ID      int    `csv:"id,required"`
+		Email   string `csv:",alias=mail"`
+		Phone   string
+		Address address
+	}
+
+	It("should report how the header maps onto a struct", func() {
+		decoder := csvencoding.NewDecoder(reader("ID,mail,phnoe,address.city,notes\n1,a@x.com,555,paris,vip\n"))
+		report, err := decoder.Plan(&customer{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(report).Should(Equal(&csvencoding.MappingReport{
+			Matched: []csvencoding.ColumnMapping{
+				{Column: "ID", Index: 0, Field: "ID"},
+				{Column: "mail", Index: 1, Field: "Email"},
+				{Column: "address.city", Index: 3, Field: "Address.City"},
+			},
+			Unmapped: []string{"phnoe", "notes"},
+			Missing:  []string{"Address.Country"},
+			Suggestions: []csvencoding.Suggestion{
+				{Column: "phnoe", Field: "Phone", Expected: "phone", Distance: 1},
+			},
+		}))
+
+		// Nothing was read
+		var output customer
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output.Phone).Should(BeEmpty())
+		Ω(output.Address.City).Should(Equal("paris"))
+	})
+
+	It("should only plan structs", func() {
+		decoder := csvencoding.NewDecoder(reader("id\n1\n"))
+		_, err := decoder.Plan(map[string]string{})
+		Ω(err).Should(MatchError("Can't plan csv decoding into map[string]string"))
+	})
+})
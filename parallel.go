@@ -0,0 +1,204 @@
+package csvencoding
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// A ParallelDecoder reads records on one goroutine and decodes them
+// on several, records are returned in the order they were read.
+// At most a few batches per worker are held in memory
+type ParallelDecoder[T any] struct {
+	dec *Decoder
+	// Results in read order, bounded to hold back the reader
+	pending chan chan parallelResult[T]
+	done    chan struct{}
+	close   sync.Once
+	// The batch being returned by Decode
+	batch parallelResult[T]
+	err   error
+}
+
+// Records are handed to workers in batches,
+// a channel send per record costs more than decoding it
+const parallelBatchSize = 64
+
+type parallelJob[T any] struct {
+	records [][]string
+	lines   []int
+	result  chan parallelResult[T]
+}
+
+type parallelResult[T any] struct {
+	values []T
+	// Ends the batch, after any values
+	err error
+}
+
+var errDecoderClosed = errors.New("decoder closed")
+
+// NewParallelDecoder decodes the records of dec on workers goroutines,
+// GOMAXPROCS when workers < 1. dec must not be used directly afterwards
+func NewParallelDecoder[T any](dec *Decoder, workers int) *ParallelDecoder[T] {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pd := &ParallelDecoder[T]{
+		dec:     dec,
+		pending: make(chan chan parallelResult[T], 2*workers),
+		done:    make(chan struct{}),
+	}
+
+	dec.start()
+	if dec.err != nil {
+		pd.err = dec.err
+		return pd
+	}
+	// The uniqueness checks of a TableSchema see every row in order
+	if dec.TableSchema != nil {
+		pd.err = fmt.Errorf("TableSchema validation can't be decoded in parallel")
+		return pd
+	}
+
+	// Plans are built up front so workers only read them
+	_, target := newTarget[T]()
+	if v := reflect.ValueOf(target); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		if _, err := dec.planFor(v.Elem().Type()); err != nil {
+			pd.err = err
+			return pd
+		}
+	} else if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Interface {
+		// A variant that fails to plan stores nothing, its
+		// records fail as they would decoded one by one
+		for _, variant := range dec.variants {
+			if variant.typ != nil && variant.typ.Kind() == reflect.Struct {
+				dec.planFor(variant.typ)
+			}
+		}
+	}
+
+	jobs := make(chan parallelJob[T], workers)
+	go pd.read(jobs)
+	for i := 0; i < workers; i++ {
+		go pd.work(jobs)
+	}
+	return pd
+}
+
+func (pd *ParallelDecoder[T]) read(jobs chan<- parallelJob[T]) {
+	defer close(jobs)
+	for {
+		job := parallelJob[T]{result: make(chan parallelResult[T], 1)}
+		var err error
+		for len(job.records) < parallelBatchSize {
+			var record []string
+			if record, err = pd.dec.read(pd.dec.readRecord()); err != nil {
+				break
+			}
+			// The record is decoded after later ones are read
+			if r, ok := pd.dec.r.(*csv.Reader); ok && r.ReuseRecord {
+				record = append([]string(nil), record...)
+			}
+			job.records = append(job.records, record)
+			job.lines = append(job.lines, pd.dec.line)
+		}
+
+		select {
+		case pd.pending <- job.result:
+		case <-pd.done:
+			return
+		}
+
+		// EOF and parse errors are returned after the records before them
+		if err != nil {
+			values, decodeErr := pd.decode(job)
+			if decodeErr == nil {
+				decodeErr = err
+			}
+			job.result <- parallelResult[T]{values, decodeErr}
+			return
+		}
+
+		select {
+		case jobs <- job:
+		case <-pd.done:
+			return
+		}
+	}
+}
+
+func (pd *ParallelDecoder[T]) work(jobs <-chan parallelJob[T]) {
+	for job := range jobs {
+		values, err := pd.decode(job)
+		job.result <- parallelResult[T]{values, err}
+	}
+}
+
+// Decode a batch, stopping at the first bad record
+func (pd *ParallelDecoder[T]) decode(job parallelJob[T]) ([]T, error) {
+	values := make([]T, 0, len(job.records))
+	for i, record := range job.records {
+		value, target := newTarget[T]()
+		if err := pd.dec.decodeTo(record, target, job.lines[i]); err != nil {
+			return values, &RecordError{Line: job.lines[i], Err: err}
+		}
+		values = append(values, *value)
+	}
+	return values, nil
+}
+
+// Decode the next record into v. Like Decoder.Decode errors are
+// sticky, io.EOF is returned at the end of the input
+func (pd *ParallelDecoder[T]) Decode(v *T) error {
+	return pd.DecodeContext(context.Background(), v)
+}
+
+// DecodeContext is Decode that gives up when ctx is done, the
+// goroutines are stopped and the error, which wraps ctx.Err(), is sticky
+func (pd *ParallelDecoder[T]) DecodeContext(ctx context.Context, v *T) error {
+	if pd.err == nil && ctx.Err() != nil {
+		pd.err = canceled("decode", ctx)
+		pd.Close()
+	}
+	for len(pd.batch.values) == 0 {
+		if pd.err != nil {
+			return pd.err
+		}
+		if pd.batch.err != nil {
+			pd.err = pd.batch.err
+			pd.Close()
+			return pd.err
+		}
+		select {
+		case result := <-pd.pending:
+			pd.batch = <-result
+		case <-pd.done:
+			pd.err = errDecoderClosed
+			return pd.err
+		case <-ctx.Done():
+			pd.err = canceled("decode", ctx)
+			pd.Close()
+			return pd.err
+		}
+	}
+	*v = pd.batch.values[0]
+	pd.batch.values = pd.batch.values[1:]
+	return nil
+}
+
+// Close stops the goroutines, it must be called if
+// Decode isn't called until it returns an error
+func (pd *ParallelDecoder[T]) Close() error {
+	pd.close.Do(func() {
+		close(pd.done)
+	})
+	if pd.err == nil {
+		pd.err = errDecoderClosed
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package csvencoding_test
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type parallelRecord struct {
+	ID    int
+	Name  string
+	Score float64
+	Tags  []string
+}
+
+func parallelInput(records int) string {
+	var b strings.Builder
+	b.WriteString("id,name,score,tags\n")
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&b, "%d,name %d,%d.5,\"a,b,c\"\n", i, i, i)
+	}
+	return b.String()
+}
+
+var _ = Describe("Parallel decoding", func() {
+	It("should return records in order", func() {
+		decoder := csvencoding.NewParallelDecoder[parallelRecord](csvencoding.NewDecoder(reader(parallelInput(1000))), 8)
+		for i := 0; i < 1000; i++ {
+			var output parallelRecord
+			Ω(decoder.Decode(&output)).Should(Succeed())
+			Ω(output.ID).Should(Equal(i))
+		}
+		var output parallelRecord
+		Ω(decoder.Decode(&output)).Should(Equal(io.EOF))
+		Ω(decoder.Decode(&output)).Should(Equal(io.EOF))
+	})
+
+	It("should decode pointers and dynamic rows", func() {
+		decoder := csvencoding.NewParallelDecoder[*parallelRecord](csvencoding.NewDecoder(reader(parallelInput(2))), 2)
+		var output *parallelRecord
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output.Name).Should(Equal("name 0"))
+		decoder.Close()
+
+		rows := csvencoding.NewParallelDecoder[map[string]string](csvencoding.NewDecoder(reader(parallelInput(2))), 2)
+		var row map[string]string
+		Ω(rows.Decode(&row)).Should(Succeed())
+		Ω(row["name"]).Should(Equal("name 0"))
+		rows.Close()
+	})
+
+	It("should report the line of a bad record", func() {
+		input := "id,name,score,tags\n1,vin,1,a\n2,\"dom\ntoretto\",1,a\nthree,hobbs,1,a\n4,shaw,1,a\n"
+		decoder := csvencoding.NewParallelDecoder[parallelRecord](csvencoding.NewDecoder(reader(input)), 4)
+		var output parallelRecord
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output.Name).Should(Equal("dom\ntoretto"))
+		err := decoder.Decode(&output)
+		var recordErr *csvencoding.RecordError
+		Ω(errors.As(err, &recordErr)).Should(BeTrue())
+		Ω(recordErr.Line).Should(Equal(5))
+		Ω(decoder.Decode(&output)).Should(Equal(err))
+	})
+
+	It("should stop when closed", func() {
+		decoder := csvencoding.NewParallelDecoder[parallelRecord](csvencoding.NewDecoder(reader(parallelInput(100))), 2)
+		decoder.Close()
+		var output parallelRecord
+		Ω(decoder.Decode(&output)).Should(HaveOccurred())
+	})
+})
+
+func benchmarkInput(b *testing.B) string {
+	input := parallelInput(20000)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	return input
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	input := benchmarkInput(b)
+	for i := 0; i < b.N; i++ {
+		decoder := csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input)))
+		var output parallelRecord
+		for decoder.Decode(&output) == nil {
+		}
+	}
+}
+
+func BenchmarkParallelDecoder(b *testing.B) {
+	input := benchmarkInput(b)
+	for i := 0; i < b.N; i++ {
+		decoder := csvencoding.NewParallelDecoder[parallelRecord](csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input))), 0)
+		var output parallelRecord
+		for decoder.Decode(&output) == nil {
+		}
+	}
+}
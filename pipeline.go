@@ -0,0 +1,243 @@
+package csvencoding
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// A Stage turns each In of a stream into any number of Out
+type Stage[In, Out any] struct {
+	// Each run has its own state, such as the values a Batch
+	// holds, so a Stage can be shared by Pipelines
+	start func() stageRun[In, Out]
+}
+
+// A Stage processing one stream
+type stageRun[In, Out any] struct {
+	push func(in In, emit func(Out) error) error
+	// Emits anything held back once the stream ends
+	flush func(emit func(Out) error) error
+}
+
+// A Stage without state
+func stateless[In, Out any](push func(in In, emit func(Out) error) error) Stage[In, Out] {
+	return Stage[In, Out]{start: func() stageRun[In, Out] {
+		return stageRun[In, Out]{push: push}
+	}}
+}
+
+// Filter keeps the values keep returns true for
+func Filter[T any](keep func(T) bool) Stage[T, T] {
+	return stateless(func(in T, emit func(T) error) error {
+		if !keep(in) {
+			return nil
+		}
+		return emit(in)
+	})
+}
+
+// Map converts each value, an error stops the Pipeline
+func Map[In, Out any](f func(In) (Out, error)) Stage[In, Out] {
+	return stateless(func(in In, emit func(Out) error) error {
+		out, err := f(in)
+		if err != nil {
+			return err
+		}
+		return emit(out)
+	})
+}
+
+// FlatMap converts each value into any number of values
+func FlatMap[In, Out any](f func(In) ([]Out, error)) Stage[In, Out] {
+	return stateless(func(in In, emit func(Out) error) error {
+		outs, err := f(in)
+		if err != nil {
+			return err
+		}
+		for _, out := range outs {
+			if err := emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Batch groups values into slices of size, for lookups or writes
+// that are cheaper in bulk, the last batch may be smaller
+func Batch[In, Out any](size int, f func([]In) ([]Out, error)) Stage[In, Out] {
+	return Stage[In, Out]{start: func() stageRun[In, Out] {
+		batch := make([]In, 0, size)
+		run := func(emit func(Out) error) error {
+			if len(batch) == 0 {
+				return nil
+			}
+			outs, err := f(batch)
+			batch = make([]In, 0, size)
+			if err != nil {
+				return err
+			}
+			for _, out := range outs {
+				if err := emit(out); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return stageRun[In, Out]{
+			push: func(in In, emit func(Out) error) error {
+				if batch = append(batch, in); len(batch) < size {
+					return nil
+				}
+				return run(emit)
+			},
+			flush: run,
+		}
+	}}
+}
+
+// Then feeds the output of first into second
+func Then[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return Stage[A, C]{start: func() stageRun[A, C] {
+		first, second := first.start(), second.start()
+		return stageRun[A, C]{
+			push: func(in A, emit func(C) error) error {
+				return first.push(in, func(b B) error {
+					return second.push(b, emit)
+				})
+			},
+			flush: func(emit func(C) error) error {
+				if first.flush != nil {
+					err := first.flush(func(b B) error {
+						return second.push(b, emit)
+					})
+					if err != nil {
+						return err
+					}
+				}
+				if second.flush != nil {
+					return second.flush(emit)
+				}
+				return nil
+			},
+		}
+	}}
+}
+
+// A Pipeline decodes records as In, passes them through a Stage
+// and encodes the resulting Out
+type Pipeline[In, Out any] struct {
+	dec   *Decoder
+	enc   *Encoder
+	stage Stage[In, Out]
+	// Records decoded ahead of the stage, bounded so a slow
+	// stage or encoder holds back the decoder
+	Buffer int
+	// Called with errors that leave the Decoder usable, such as a
+	// *ValidationError, return nil to skip the record.
+	// When nil the Pipeline stops on the first error
+	OnError func(error) error
+}
+
+func NewPipeline[In, Out any](dec *Decoder, enc *Encoder, stage Stage[In, Out]) *Pipeline[In, Out] {
+	return &Pipeline[In, Out]{dec: dec, enc: enc, stage: stage}
+}
+
+// A decoded record handed from the decoding goroutine
+type decoded[T any] struct {
+	value T
+	err   error
+	// The Decoder can't continue after this error
+	fatal bool
+}
+
+// Run processes every record, it stops at the end of the input,
+// the first error from a stage or the Encoder, or a fatal decode error
+func (p *Pipeline[In, Out]) Run() error {
+	return p.RunContext(context.Background())
+}
+
+// RunContext is Run that stops between records once ctx is done,
+// returning an error that wraps ctx.Err(). It returns once the
+// decoding goroutine is done with the Decoder, wrap a reader
+// that may block with ContextReader so it isn't waited out
+func (p *Pipeline[In, Out]) RunContext(ctx context.Context) error {
+	records := make(chan decoded[In], p.Buffer)
+	done := make(chan struct{})
+	var decoding sync.WaitGroup
+	defer decoding.Wait()
+	defer close(done)
+
+	decoding.Add(1)
+	go func() {
+		defer decoding.Done()
+		defer close(records)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			var r decoded[In]
+			r.value, r.err = decodeValue[In](ctx, p.dec)
+			r.fatal = p.dec.err != nil
+			select {
+			case records <- r:
+			case <-done:
+				return
+			}
+			if r.fatal {
+				return
+			}
+		}
+	}()
+
+	stage := p.stage.start()
+	emit := func(out Out) error {
+		return p.enc.EncodeContext(ctx, out)
+	}
+
+	for r := range records {
+		if r.err == io.EOF {
+			break
+		}
+		if r.err != nil {
+			if r.fatal || p.OnError == nil {
+				return r.err
+			}
+			if err := p.OnError(r.err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := stage.push(r.value, emit); err != nil {
+			return err
+		}
+	}
+
+	if stage.flush != nil {
+		return stage.flush(emit)
+	}
+	return nil
+}
+
+// Decode the next record as a T
+func decodeValue[T any](ctx context.Context, dec *Decoder) (T, error) {
+	value, target := newTarget[T]()
+	err := dec.DecodeContext(ctx, target)
+	return *value, err
+}
+
+// A T to decode into and the pointer Decode needs,
+// when T is itself a pointer it is allocated
+func newTarget[T any]() (*T, interface{}) {
+	value := new(T)
+	target := interface{}(value)
+	if v := reflect.ValueOf(value).Elem(); v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		target = v.Interface()
+	}
+	return value, target
+}
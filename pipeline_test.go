@@ -0,0 +1,134 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pipeline", func() {
+	type person struct {
+		Name string
+		Age  int
+	}
+	type adult struct {
+		Name string
+	}
+
+	var b bytes.Buffer
+	var encoder *csvencoding.Encoder
+
+	BeforeEach(func() {
+		b.Reset()
+		encoder = csvencoding.NewEncoder(csv.NewWriter(&b))
+	})
+
+	input := "name,age\nvin,50\nbaby groot,1\ndom,45\n"
+
+	It("should filter and map records", func() {
+		stage := csvencoding.Then(
+			csvencoding.Filter(func(p person) bool { return p.Age >= 18 }),
+			csvencoding.Map(func(p person) (adult, error) { return adult{strings.ToUpper(p.Name)}, nil }),
+		)
+		pipeline := csvencoding.NewPipeline(csvencoding.NewDecoder(reader(input)), encoder, stage)
+		Ω(pipeline.Run()).Should(Succeed())
+		Ω(b.String()).Should(Equal("VIN\nDOM\n"))
+	})
+
+	It("should flat map records", func() {
+		stage := csvencoding.FlatMap(func(p *person) ([]adult, error) {
+			return []adult{{p.Name}, {p.Name}}, nil
+		})
+		pipeline := csvencoding.NewPipeline(csvencoding.NewDecoder(reader("name,age\nvin,50\n")), encoder, stage)
+		Ω(pipeline.Run()).Should(Succeed())
+		Ω(b.String()).Should(Equal("vin\nvin\n"))
+	})
+
+	It("should batch records", func() {
+		sizes := []int{}
+		stage := csvencoding.Batch(2, func(people []person) ([]adult, error) {
+			sizes = append(sizes, len(people))
+			adults := []adult{}
+			for _, p := range people {
+				adults = append(adults, adult{p.Name})
+			}
+			return adults, nil
+		})
+		pipeline := csvencoding.NewPipeline(csvencoding.NewDecoder(reader(input)), encoder, stage)
+		pipeline.Buffer = 1
+		Ω(pipeline.Run()).Should(Succeed())
+		Ω(sizes).Should(Equal([]int{2, 1}))
+		Ω(b.String()).Should(Equal("vin\nbaby groot\ndom\n"))
+	})
+
+	It("should start each Pipeline with an empty batch", func() {
+		batches := [][]person{}
+		stage := csvencoding.Batch(2, func(people []person) ([]adult, error) {
+			batches = append(batches, people)
+			return nil, nil
+		})
+		// Stops on the bad record with vin held back
+		pipeline := csvencoding.NewPipeline(csvencoding.NewDecoder(reader("name,age\nvin,50\ndom,fifty\n")), encoder, stage)
+		Ω(pipeline.Run()).Should(HaveOccurred())
+		pipeline = csvencoding.NewPipeline(csvencoding.NewDecoder(reader("name,age\ndom,45\nletty,40\n")), encoder, stage)
+		Ω(pipeline.Run()).Should(Succeed())
+		Ω(batches).Should(Equal([][]person{{{"dom", 45}, {"letty", 40}}}))
+	})
+
+	It("should be done with the Decoder when it returns", func() {
+		failure := errors.New("stop")
+		decoder := csvencoding.NewDecoder(reader(input))
+		stage := csvencoding.Map(func(p person) (adult, error) { return adult{}, failure })
+		pipeline := csvencoding.NewPipeline(decoder, encoder, stage)
+		Ω(pipeline.Run()).Should(Equal(failure))
+		// Raced with the decoding goroutine before it was waited for
+		var p person
+		decoder.Decode(&p)
+	})
+
+	It("should stop on stage errors", func() {
+		failure := errors.New("no groot")
+		stage := csvencoding.Map(func(p person) (adult, error) {
+			if p.Age < 18 {
+				return adult{}, failure
+			}
+			return adult{p.Name}, nil
+		})
+		pipeline := csvencoding.NewPipeline(csvencoding.NewDecoder(reader(input)), encoder, stage)
+		Ω(pipeline.Run()).Should(Equal(failure))
+		Ω(b.String()).Should(Equal("vin\n"))
+	})
+
+	It("should stop on fatal decode errors", func() {
+		stage := csvencoding.Map(func(p person) (adult, error) { return adult{p.Name}, nil })
+		pipeline := csvencoding.NewPipeline(csvencoding.NewDecoder(reader("name,age\nvin,fifty\ndom,45\n")), encoder, stage)
+		pipeline.OnError = func(error) error { return nil }
+		Ω(pipeline.Run()).Should(HaveOccurred())
+		Ω(b.String()).Should(Equal(""))
+	})
+
+	It("should let OnError skip invalid rows", func() {
+		schema, err := csvencoding.LoadTableSchema(strings.NewReader(
+			`{"fields": [{"name": "name"}, {"name": "age", "type": "integer", "constraints": {"minimum": 18}}]}`))
+		Ω(err).Should(BeNil())
+		decoder := csvencoding.NewDecoder(reader(input))
+		decoder.TableSchema = schema
+		skipped := 0
+		stage := csvencoding.Map(func(row map[string]interface{}) ([]string, error) {
+			return []string{row["name"].(string)}, nil
+		})
+		pipeline := csvencoding.NewPipeline(decoder, encoder, stage)
+		pipeline.OnError = func(err error) error {
+			skipped++
+			return nil
+		}
+		Ω(pipeline.Run()).Should(Succeed())
+		Ω(skipped).Should(Equal(1))
+		Ω(b.String()).Should(Equal("vin\ndom\n"))
+	})
+})
@@ -0,0 +1,82 @@
+package csvencoding
+
+import (
+	"encoding/csv"
+	"fmt"
+)
+
+// A RaggedPolicy decides how records with more or fewer cells
+// than the header are decoded. Fields tagged csv:",rest" collect
+// the extra cells of long records whatever the policy
+type RaggedPolicy int
+
+const (
+	// The zero value, as RaggedError except that when NewDecoder's
+	// csv.Reader has a negative FieldsPerRecord the extra cells of
+	// long records are dropped, as they always have been
+	RaggedDefault RaggedPolicy = iota
+	// Fail the Decode, the error wraps csv.ErrFieldCount
+	RaggedError
+	// Pad short records with the EmptyValue, long records fail
+	RaggedPadEmpty
+	// Pad short records with the NilValue, long records fail
+	RaggedPadNil
+	// Drop the extra cells of long records, short records
+	// are padded with the EmptyValue
+	RaggedTruncate
+)
+
+// The cell missing cells of short records read as, ok is
+// false when short records fail
+func (dec *Decoder) padValue() (value string, ok bool) {
+	switch dec.Ragged {
+	case RaggedPadEmpty, RaggedTruncate:
+		return dec.EmptyValue, true
+	case RaggedPadNil:
+		return dec.NilValue, true
+	}
+	return "", false
+}
+
+// Fit a record to the header by the RaggedPolicy, rest is
+// true when the target collects the extra cells
+func (dec *Decoder) fit(r []string, rest bool) ([]string, error) {
+	if err := dec.checkFit(len(r), rest); err != nil {
+		return nil, err
+	}
+	width := len(dec.header)
+	switch {
+	case width == 0 || len(r) == width:
+		return r, nil
+	case len(r) > width:
+		if rest {
+			return r, nil
+		}
+		return r[:width], nil
+	}
+
+	pad, _ := dec.padValue()
+	// A copy, r may be reused by the next read
+	padded := make([]string, width)
+	copy(padded, r)
+	for i := len(r); i < width; i++ {
+		padded[i] = pad
+	}
+	return padded, nil
+}
+
+// Can a record of n cells be fit to the header
+func (dec *Decoder) checkFit(n int, rest bool) error {
+	width := len(dec.header)
+	if width == 0 || n == width {
+		return nil
+	}
+	if n > width {
+		if rest || dec.Ragged == RaggedTruncate || (dec.Ragged == RaggedDefault && dec.variableFields) {
+			return nil
+		}
+	} else if _, ok := dec.padValue(); ok {
+		return nil
+	}
+	return fmt.Errorf("%w, the record has %d and the header %d", csv.ErrFieldCount, n, width)
+}
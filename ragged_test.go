@@ -0,0 +1,127 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ragged records", func() {
+	type person struct {
+		Name string
+		Age  *int
+		City string
+	}
+	input := "name,age,city\nbob\nsue,40,rome,extra\n"
+
+	decoder := func(policy csvencoding.RaggedPolicy) *csvencoding.Decoder {
+		r := csv.NewReader(strings.NewReader(input))
+		r.FieldsPerRecord = -1
+		decoder := csvencoding.NewDecoder(r)
+		decoder.Ragged = policy
+		return decoder
+	}
+
+	It("should fail ragged records", func() {
+		for _, decoder := range []*csvencoding.Decoder{
+			decoder(csvencoding.RaggedError),
+			// Without FieldsPerRecord = -1 too
+			csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input))),
+			csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{}),
+		} {
+			var output person
+			err := decoder.Decode(&output)
+			Ω(errors.Is(err, csv.ErrFieldCount)).Should(BeTrue())
+			Ω(err).Should(MatchError("line 2: wrong number of fields, the record has 1 and the header 3"))
+		}
+	})
+
+	It("should keep ignoring the rest of long records of variable length readers", func() {
+		variable := func(policy csvencoding.RaggedPolicy) *csvencoding.Decoder {
+			r := csv.NewReader(strings.NewReader("name,age,city\nsue,40,rome,extra\nbob\n"))
+			r.FieldsPerRecord = -1
+			decoder := csvencoding.NewDecoder(r)
+			decoder.Ragged = policy
+			return decoder
+		}
+		decoder := variable(csvencoding.RaggedDefault)
+		var output person
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output.City).Should(Equal("rome"))
+		Ω(errors.Is(decoder.Decode(&output), csv.ErrFieldCount)).Should(BeTrue())
+
+		// Unless RaggedError is asked for
+		decoder = variable(csvencoding.RaggedError)
+		err := decoder.Decode(&output)
+		Ω(errors.Is(err, csv.ErrFieldCount)).Should(BeTrue())
+		Ω(err).Should(MatchError("line 2: wrong number of fields, the record has 4 and the header 3"))
+	})
+
+	It("should pad short records", func() {
+		decoder := decoder(csvencoding.RaggedPadNil)
+		var output person
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output.Name).Should(Equal("bob"))
+		Ω(output.Age).Should(BeNil())
+		err := decoder.Decode(&output)
+		Ω(err).Should(MatchError("line 3: wrong number of fields, the record has 4 and the header 3"))
+
+		decoder = csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		decoder.Ragged = csvencoding.RaggedPadEmpty
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(*output.Age).Should(Equal(0))
+	})
+
+	It("should truncate long records", func() {
+		decoder := decoder(csvencoding.RaggedTruncate)
+		var output map[string]string
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(map[string]string{"name": "bob", "age": "", "city": ""}))
+		output = nil
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(map[string]string{"name": "sue", "age": "40", "city": "rome"}))
+		Ω(decoder.Decode(&output)).Should(Equal(io.EOF))
+	})
+
+	It("should collect the rest of long records", func() {
+		type visit struct {
+			Name string
+			Rest []string `csv:",rest"`
+		}
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader("name\nbob,paris,rome\nsue\n"), csvencoding.Dialect{})
+		var output visit
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(visit{"bob", []string{"paris", "rome"}}))
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(visit{"sue", nil}))
+
+		// Positional fields without a header
+		type row struct {
+			ID   int      `csv:",index=0"`
+			Tags []string `csv:",rest"`
+		}
+		decoder = csvencoding.NewDecoderNoHeader(csv.NewReader(strings.NewReader("1,a,b\n")))
+		var r row
+		Ω(decoder.Decode(&r)).Should(Succeed())
+		Ω(r).Should(Equal(row{1, []string{"a", "b"}}))
+
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewEncoder(csv.NewWriter(buffer))
+		Ω(encoder.Encode(visit{"bob", []string{"paris"}})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("bob\n"))
+	})
+
+	It("should only collect into []string", func() {
+		type visit struct {
+			Rest string `csv:",rest"`
+		}
+		decoder := csvencoding.NewDecoder(reader("name\nbob\n"))
+		Ω(decoder.Decode(&visit{})).Should(MatchError("line 2: Can't collect the rest of a record into string, use []string"))
+	})
+})
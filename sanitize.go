@@ -0,0 +1,91 @@
+package csvencoding
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A SanitizePolicy decides how cells a spreadsheet would evaluate
+// as a formula, those starting with = + - @ tab or CR, are written
+type SanitizePolicy int
+
+const (
+	// Write cells as they are
+	SanitizeNone SanitizePolicy = iota
+	// Prefix with ', which spreadsheets show as text, =1+1 -> '=1+1
+	SanitizePrefix
+	// Fail the Encode
+	SanitizeReject
+	// Write a formula evaluating to the text, =1+1 -> ="=1+1"
+	SanitizeEscape
+)
+
+// NewExcelEncoder encodes to w for Excel, with a UTF-8 byte order mark
+// and CRLF line endings, formulas prefixed and numbers that
+// Excel would mangle protected
+func NewExcelEncoder(w io.Writer) *Encoder {
+	enc := NewWriterEncoder(w, Excel)
+	enc.SanitizeFormulas = SanitizePrefix
+	enc.ProtectNumbers = true
+	return enc
+}
+
+// Sanitize a record in place, the cells of columns tagged
+// csv:",noSanitize" are left alone. l is nil for dynamic rows
+func (enc *Encoder) sanitize(cells []string, l *layout) error {
+	if enc.SanitizeFormulas == SanitizeNone && !enc.ProtectNumbers {
+		return nil
+	}
+	for i, cell := range cells {
+		if l != nil && len(cells) == l.cells && l.columns[i].tag.has("noSanitize") {
+			continue
+		}
+		sanitized, err := enc.sanitizeCell(cell)
+		if err != nil {
+			return err
+		}
+		cells[i] = sanitized
+	}
+	return nil
+}
+
+func (enc *Encoder) sanitizeCell(cell string) (string, error) {
+	if enc.ProtectNumbers && mangledNumber(cell) {
+		return textFormula(cell), nil
+	}
+	if enc.SanitizeFormulas == SanitizeNone || cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell, nil
+	}
+	// -1.5 is a number not a formula
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell, nil
+	}
+	switch enc.SanitizeFormulas {
+	case SanitizePrefix:
+		return "'" + cell, nil
+	case SanitizeReject:
+		return "", fmt.Errorf("cell `%s` would be evaluated as a formula", cell)
+	}
+	return textFormula(cell), nil
+}
+
+// Excel shows numbers of more than 11 digits in scientific notation,
+// rounds those of more than 15 and drops leading zeros
+func mangledNumber(cell string) bool {
+	if len(cell) < 2 {
+		return false
+	}
+	for _, r := range cell {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(cell) > 11 || cell[0] == '0'
+}
+
+// A formula evaluating to the text s
+func textFormula(s string) string {
+	return `="` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
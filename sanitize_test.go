@@ -0,0 +1,58 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Formula sanitizing", func() {
+	type comment struct {
+		Author  string
+		Body    string
+		Formula string `csv:"formula,noSanitize"`
+		Score   int
+	}
+	input := comment{"@bob", "=HYPERLINK(\"http://evil\")", "=1+1", -3}
+
+	encode := func(policy csvencoding.SanitizePolicy, i interface{}) (string, error) {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewEncoder(csv.NewWriter(buffer))
+		encoder.SanitizeFormulas = policy
+		err := encoder.Encode(i)
+		return buffer.String(), err
+	}
+
+	It("should prefix formulas", func() {
+		output, err := encode(csvencoding.SanitizePrefix, input)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal("'@bob,\"'=HYPERLINK(\"\"http://evil\"\")\",=1+1,-3\n"))
+	})
+
+	It("should escape formulas", func() {
+		output, err := encode(csvencoding.SanitizeEscape, []string{"-2+3", "-2.5", "\tx"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal("\"=\"\"-2+3\"\"\",-2.5,\"=\"\"\tx\"\"\"\n"))
+	})
+
+	It("should reject formulas", func() {
+		_, err := encode(csvencoding.SanitizeReject, map[string]string{"name": "+cmd"})
+		Ω(err).Should(MatchError("cell `+cmd` would be evaluated as a formula"))
+	})
+
+	It("should write for Excel", func() {
+		type account struct {
+			ID   string
+			Zip  string
+			Name string
+		}
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewExcelEncoder(buffer)
+		Ω(encoder.Encode(account{"4111111111111111", "08001", "=bob"})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("\xEF\xBB\xBFid,zip,name\r\n" +
+			"\"=\"\"4111111111111111\"\"\",\"=\"\"08001\"\"\",'=bob\r\n"))
+	})
+})
@@ -0,0 +1,230 @@
+package csvencoding
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A Column describes one column of an encoded record
+type Column struct {
+	// Dotted name as written in the header, person.name
+	Name string `json:"name"`
+	// The go kind of the value, time.Time is "time"
+	Kind string `json:"kind"`
+	// The go type of the value, ie *int64
+	Type string `json:"type"`
+	// Written as the NilValue when it, or a parent struct, is nil
+	Nullable bool `json:"nullable"`
+	// Time layout from the format tag, csv:",format=2006-01-02"
+	Format string `json:"format,omitempty"`
+	// From the required tag, csv:",required"
+	Required bool `json:"required"`
+
+	typ reflect.Type
+}
+
+// A RecordSchema describes the records an Encoder writes for a struct,
+// column by column, it can be published as a JSON Schema or a
+// Frictionless Data Table Schema so files are checked without go
+type RecordSchema struct {
+	Columns []Column `json:"columns"`
+	// The cell value written for nil
+	NilValue string `json:"nilValue"`
+}
+
+// Schema describes the columns NewEncoder writes for v
+func Schema(v interface{}) (*RecordSchema, error) {
+	return NewEncoder(csv.NewWriter(nil)).Schema(v)
+}
+
+// Schema describes the columns Encode writes for v, taking into
+// account the NameMapper and Columns of enc
+func (enc *Encoder) Schema(v interface{}) (*RecordSchema, error) {
+	reflectType := reflect.TypeOf(v)
+	if reflectType == nil {
+		return nil, fmt.Errorf("Can't describe the csv schema of nil")
+	}
+
+	l, err := enc.layoutFor(reflectType)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &RecordSchema{NilValue: enc.NilValue}
+	for i, name := range l.header {
+		source := i
+		if l.sources != nil {
+			source = l.sources[i]
+		}
+		// Gaps between positional columns
+		if source < 0 {
+			continue
+		}
+		column := l.columns[source]
+
+		kind := column.typ.Kind().String()
+		if column.typ == timeType {
+			kind = "time"
+		}
+		typeName := column.typ.String()
+		if column.nullable && column.typ.Kind() != reflect.Interface {
+			typeName = "*" + typeName
+		}
+		format, _ := column.tag.value("format")
+
+		schema.Columns = append(schema.Columns, Column{
+			Name:     name,
+			Kind:     kind,
+			Type:     typeName,
+			Nullable: column.nullable,
+			Format:   format,
+			Required: column.tag.has("required"),
+			typ:      column.typ,
+		})
+	}
+	return schema, nil
+}
+
+// A JSONSchema describes a record as a JSON object keyed by column name
+type JSONSchema struct {
+	Schema     string                         `json:"$schema"`
+	Type       string                         `json:"type"`
+	Properties map[string]*JSONSchemaProperty `json:"properties"`
+	Required   []string                       `json:"required,omitempty"`
+}
+
+type JSONSchemaProperty struct {
+	// A type, or a type and "null" for nullable columns
+	Type    interface{} `json:"type"`
+	Format  string      `json:"format,omitempty"`
+	Minimum json.Number `json:"minimum,omitempty"`
+	Maximum json.Number `json:"maximum,omitempty"`
+}
+
+// JSONSchema returns s as a draft-07 JSON Schema
+func (s *RecordSchema) JSONSchema() *JSONSchema {
+	schema := &JSONSchema{
+		Schema:     "http://json-schema.org/draft-07/schema#",
+		Type:       "object",
+		Properties: make(map[string]*JSONSchemaProperty, len(s.Columns)),
+	}
+	for _, column := range s.Columns {
+		property := &JSONSchemaProperty{Type: "string"}
+		switch column.Kind {
+		case "bool":
+			property.Type = "boolean"
+		case "float32", "float64":
+			property.Type = "number"
+		case "time":
+			if column.Format == "" {
+				property.Format = "date-time"
+			}
+		default:
+			if min, max, ok := intRange(column.typ); ok {
+				property.Type = "integer"
+				property.Minimum, property.Maximum = min, max
+			}
+		}
+		if column.Nullable {
+			property.Type = []string{property.Type.(string), "null"}
+		}
+		if column.Required {
+			schema.Required = append(schema.Required, column.Name)
+		}
+		schema.Properties[column.Name] = property
+	}
+	return schema
+}
+
+// TableSchema returns s as a Frictionless Data Table Schema,
+// the contents of a tableschema.json
+func (s *RecordSchema) TableSchema() *TableSchema {
+	schema := &TableSchema{MissingValues: []string{s.NilValue}}
+	for _, column := range s.Columns {
+		field := TableField{Name: column.Name, Type: "string"}
+		constraints := &FieldConstraints{Required: column.Required}
+		switch column.Kind {
+		case "bool":
+			field.Type = "boolean"
+		case "float32", "float64":
+			field.Type = "number"
+		case "time":
+			field.Type = "datetime"
+			if column.Format != "" {
+				field.Type = timeLayoutType(column.Format)
+				field.Format = strftime(column.Format)
+			}
+		default:
+			if min, max, ok := intRange(column.typ); ok {
+				field.Type = "integer"
+				constraints.Minimum, constraints.Maximum = min, max
+			}
+		}
+		if !constraints.empty() {
+			field.Constraints = constraints
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	return schema
+}
+
+// The range of integer types as JSON numbers, exactly
+// as a float64 can't hold the bounds of 64 bit types
+func intRange(t reflect.Type) (min, max json.Number, ok bool) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := int64(math.MaxInt64) >> (64 - t.Bits())
+		return json.Number(strconv.FormatInt(-n-1, 10)), json.Number(strconv.FormatInt(n, 10)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := uint64(math.MaxUint64) >> (64 - t.Bits())
+		return "0", json.Number(strconv.FormatUint(n, 10)), true
+	}
+	return "", "", false
+}
+
+// Go layout elements and their strftime equivalents,
+// longest first so 2006 is replaced before 06
+var layoutDirectives = []string{
+	"January", "%B",
+	"Monday", "%A",
+	"-07:00", "%z",
+	"-0700", "%z",
+	"2006", "%Y",
+	"Jan", "%b",
+	"Mon", "%a",
+	"MST", "%Z",
+	"01", "%m",
+	"02", "%d",
+	"15", "%H",
+	"03", "%I",
+	"04", "%M",
+	"05", "%S",
+	"06", "%y",
+	"PM", "%p",
+}
+
+// Frictionless formats dates with strftime directives
+func strftime(layout string) string {
+	return strings.NewReplacer(layoutDirectives...).Replace(layout)
+}
+
+// Layouts without a time of day are dates, without a day are times
+func timeLayoutType(layout string) string {
+	hasTime := strings.Contains(layout, "15") || strings.Contains(layout, "03") ||
+		strings.Contains(layout, "04") || strings.Contains(layout, "05")
+	hasDate := strings.Contains(layout, "2006") || strings.Contains(layout, "06") ||
+		strings.Contains(layout, "01") || strings.Contains(layout, "02") ||
+		strings.Contains(layout, "Jan")
+	switch {
+	case hasDate && !hasTime:
+		return "date"
+	case hasTime && !hasDate:
+		return "time"
+	}
+	return "datetime"
+}
@@ -0,0 +1,100 @@
+package csvencoding_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+var _ = Describe("Schema", func() {
+	type ChildStruct struct {
+		Name string
+		Born time.Time `csv:",format=2006-01-02"`
+	}
+	type record struct {
+		ID     uint8 `csv:",required"`
+		Score  *float64
+		Active bool
+		Child  *ChildStruct
+		At     time.Time
+	}
+
+	It("should describe each column", func() {
+		schema, err := csvencoding.Schema(record{})
+		Ω(err).Should(BeNil())
+		Ω(schema.Columns).Should(HaveLen(6))
+		Ω(schema.Columns[0]).Should(MatchFields(IgnoreExtras, Fields{
+			"Name": Equal("id"), "Kind": Equal("uint8"), "Nullable": BeFalse(), "Required": BeTrue(),
+		}))
+		Ω(schema.Columns[1]).Should(MatchFields(IgnoreExtras, Fields{
+			"Name": Equal("score"), "Type": Equal("*float64"), "Nullable": BeTrue(),
+		}))
+		Ω(schema.Columns[4]).Should(MatchFields(IgnoreExtras, Fields{
+			"Name": Equal("child.born"), "Kind": Equal("time"), "Nullable": BeTrue(), "Format": Equal("2006-01-02"),
+		}))
+	})
+
+	It("should follow the encoder's column selection", func() {
+		encoder := csvencoding.NewEncoder(nil)
+		encoder.Columns([]string{"at=timestamp", "id"})
+		schema, err := encoder.Schema(record{})
+		Ω(err).Should(BeNil())
+		Ω(schema.Columns).Should(HaveLen(2))
+		Ω(schema.Columns[0].Name).Should(Equal("timestamp"))
+	})
+
+	It("should export a JSON Schema", func() {
+		schema, err := csvencoding.Schema(record{})
+		Ω(err).Should(BeNil())
+		b, err := json.Marshal(schema.JSONSchema())
+		Ω(err).Should(BeNil())
+		Ω(b).Should(MatchJSON(`{
+			"$schema": "http://json-schema.org/draft-07/schema#",
+			"type": "object",
+			"properties": {
+				"id": {"type": "integer", "minimum": 0, "maximum": 255},
+				"score": {"type": ["number", "null"]},
+				"active": {"type": "boolean"},
+				"child.name": {"type": ["string", "null"]},
+				"child.born": {"type": ["string", "null"]},
+				"at": {"type": "string", "format": "date-time"}
+			},
+			"required": ["id"]
+		}`))
+	})
+
+	It("should export the exact range of 64 bit integers", func() {
+		type wide struct {
+			Signed   int64
+			Unsigned uint64
+		}
+		schema, err := csvencoding.Schema(wide{})
+		Ω(err).Should(BeNil())
+		b, err := json.Marshal(schema.JSONSchema().Properties)
+		Ω(err).Should(BeNil())
+		Ω(string(b)).Should(ContainSubstring(`"minimum":-9223372036854775808,"maximum":9223372036854775807`))
+		Ω(string(b)).Should(ContainSubstring(`"minimum":0,"maximum":18446744073709551615`))
+	})
+
+	It("should export a Frictionless Table Schema", func() {
+		schema, err := csvencoding.Schema(record{})
+		Ω(err).Should(BeNil())
+		b, err := json.Marshal(schema.TableSchema())
+		Ω(err).Should(BeNil())
+		Ω(b).Should(MatchJSON(`{
+			"fields": [
+				{"name": "id", "type": "integer", "constraints": {"required": true, "minimum": 0, "maximum": 255}},
+				{"name": "score", "type": "number"},
+				{"name": "active", "type": "boolean"},
+				{"name": "child.name", "type": "string"},
+				{"name": "child.born", "type": "date", "format": "%Y-%m-%d"},
+				{"name": "at", "type": "datetime"}
+			],
+			"missingValues": ["NULL"]
+		}`))
+	})
+})
@@ -0,0 +1,207 @@
+package csvencoding
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// The marker row starting a named section, #SECTION orders
+const DefaultSectionMarker = "#SECTION"
+
+// A SectionReader reads files holding several tables, each with its
+// own header. Sections are separated by blank lines, or begin with a
+// marker row naming them, "#SECTION orders". Line breaks in quoted
+// cells don't separate sections
+type SectionReader struct {
+	r *bufio.Reader
+	d Dialect
+	// Rows starting with Marker begin a section named by the rest of the row
+	Marker string
+
+	// The section being read
+	body *sectionBody
+	// The first line of the next section, read ending the last
+	next []byte
+	err  error
+}
+
+// NewSectionReader reads the sections of r as described by d
+func NewSectionReader(r io.Reader, d Dialect) *SectionReader {
+	// Charsets are decoded once, sections are split on UTF-8 lines
+	r = NewCharsetReader(r, d.Charset)
+	d.Charset = nil
+	return &SectionReader{r: bufio.NewReader(r), d: d, Marker: DefaultSectionMarker}
+}
+
+// Next returns the Decoder of the next section and its name, empty for
+// sections without a marker row. The rest of the last section is
+// skipped, io.EOF is returned after the last section. A marker row
+// without a header after it is an error. Line numbers in errors
+// are relative to the start of the section
+func (sr *SectionReader) Next() (name string, dec *Decoder, err error) {
+	if sr.body != nil {
+		for !sr.body.done {
+			sr.body.line()
+		}
+	}
+	marked := false
+	for {
+		line := sr.next
+		sr.next = nil
+		if line == nil {
+			if sr.err != nil {
+				if marked {
+					return name, nil, fmt.Errorf("section `%s` is empty", name)
+				}
+				return "", nil, sr.err
+			}
+			line, sr.err = sr.r.ReadBytes('\n')
+			if len(line) == 0 {
+				continue
+			}
+		}
+		if blankLine(line) {
+			continue
+		}
+		if section, ok := sr.marker(line); ok {
+			if marked {
+				// The next call begins with this marker
+				sr.next = line
+				return name, nil, fmt.Errorf("section `%s` is empty", name)
+			}
+			name, marked = section, true
+			continue
+		}
+
+		sr.body = &sectionBody{sr: sr, buffer: line}
+		sr.body.quoted = sr.body.quotes(line)
+		return name, NewReaderDecoder(sr.body, sr.d), nil
+	}
+}
+
+// The name of the section a marker row begins
+func (sr *SectionReader) marker(line []byte) (string, bool) {
+	if sr.Marker == "" || !bytes.HasPrefix(line, []byte(sr.Marker)) {
+		return "", false
+	}
+	comma := sr.d.Comma
+	if comma == 0 {
+		comma = ','
+	}
+	rest := strings.TrimRight(string(line[len(sr.Marker):]), "\r\n")
+	// Spreadsheets pad the row with empty cells, #SECTION orders,,,
+	rest = strings.TrimRight(rest, string(comma))
+	if r, _ := utf8.DecodeRuneInString(rest); rest != "" && r != ' ' && r != '\t' && r != comma {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimLeft(rest, string(comma))), true
+}
+
+func blankLine(line []byte) bool {
+	return len(bytes.TrimRight(line, "\r\n")) == 0
+}
+
+// The lines of a section, up to a blank line or marker row
+type sectionBody struct {
+	sr     *SectionReader
+	buffer []byte
+	// The lines so far leave a quoted cell open
+	quoted bool
+	done   bool
+}
+
+func (b *sectionBody) Read(p []byte) (int, error) {
+	for len(b.buffer) == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		b.line()
+	}
+	n := copy(p, b.buffer)
+	b.buffer = b.buffer[n:]
+	return n, nil
+}
+
+// Buffer the next line of the section
+func (b *sectionBody) line() {
+	sr := b.sr
+	if sr.err != nil {
+		b.done = true
+		return
+	}
+	var line []byte
+	line, sr.err = sr.r.ReadBytes('\n')
+	if !b.quoted && len(line) > 0 {
+		if _, ok := sr.marker(line); ok || blankLine(line) {
+			sr.next = line
+			b.done = true
+			return
+		}
+	}
+	if b.quotes(line) {
+		b.quoted = !b.quoted
+	}
+	b.buffer = line
+	if sr.err != nil {
+		b.done = true
+	}
+}
+
+// Does the line hold an odd number of quotes, opening or closing a cell
+func (b *sectionBody) quotes(line []byte) bool {
+	quote := b.sr.d.Quote
+	if quote == 0 {
+		quote = '"'
+	}
+	return bytes.Count(line, utf8.AppendRune(nil, quote))%2 == 1
+}
+
+// A SectionWriter writes several tables into one stream, each
+// section with its own header, separated by a blank line
+type SectionWriter struct {
+	w io.Writer
+	d Dialect
+	// Named sections begin with a marker row, #SECTION orders
+	Marker string
+
+	sections int
+}
+
+// NewSectionWriter writes sections to w as described by d
+func NewSectionWriter(w io.Writer, d Dialect) *SectionWriter {
+	if d.BOM || d.Charset != nil {
+		w = NewCharsetWriter(w, d.Charset, d.BOM)
+	}
+	d.BOM, d.Charset = false, nil
+	return &SectionWriter{w: w, d: d, Marker: DefaultSectionMarker}
+}
+
+// Section begins a section, named by a marker row unless name is
+// empty, and returns its Encoder. The Encoder of the last section
+// mustn't be used once the next begins
+func (sw *SectionWriter) Section(name string) (*Encoder, error) {
+	// The marker row is a single line, written as is
+	if strings.ContainsAny(name, "\r\n") {
+		return nil, fmt.Errorf("Can't name a section %q, names are a single line", name)
+	}
+	newline := "\n"
+	if sw.d.UseCRLF {
+		newline = "\r\n"
+	}
+	var start string
+	if sw.sections > 0 {
+		start = newline
+	}
+	if name != "" {
+		start += sw.Marker + " " + name + newline
+	}
+	if _, err := io.WriteString(sw.w, start); err != nil {
+		return nil, err
+	}
+	sw.sections++
+	return NewWriterEncoder(sw.w, sw.d), nil
+}
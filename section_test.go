@@ -0,0 +1,119 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sections", func() {
+	type order struct {
+		ID    int
+		Total float64
+	}
+	type item struct {
+		Order int
+		Note  string
+	}
+
+	It("should read marked sections", func() {
+		input := "#SECTION orders,,\nid,total\n1,9.5\n2,3\n\n#SECTION items\norder,note\n1,\"gift\n\nwrap\"\n"
+		sections := csvencoding.NewSectionReader(strings.NewReader(input), csvencoding.Dialect{})
+
+		name, decoder, err := sections.Next()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("orders"))
+		var o order
+		Ω(decoder.Decode(&o)).Should(Succeed())
+		Ω(o).Should(Equal(order{1, 9.5}))
+
+		// The rest of the section is skipped
+		name, decoder, err = sections.Next()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("items"))
+		var i item
+		Ω(decoder.Decode(&i)).Should(Succeed())
+		Ω(i).Should(Equal(item{1, "gift\n\nwrap"}))
+		Ω(decoder.Decode(&i)).Should(Equal(io.EOF))
+
+		_, _, err = sections.Next()
+		Ω(err).Should(Equal(io.EOF))
+	})
+
+	It("should read sections separated by blank lines", func() {
+		input := "id,total\r\n1,9.5\r\n\r\n\r\norder,note\r\n1,gift\r\n"
+		sections := csvencoding.NewSectionReader(strings.NewReader(input), csvencoding.Dialect{})
+		var headers [][]string
+		for {
+			name, decoder, err := sections.Next()
+			if err == io.EOF {
+				break
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(name).Should(BeEmpty())
+			headers = append(headers, decoder.Header())
+			var rows []map[string]string
+			for {
+				row := map[string]string{}
+				if decoder.Decode(&row) != nil {
+					break
+				}
+				rows = append(rows, row)
+			}
+			Ω(rows).Should(HaveLen(1))
+		}
+		Ω(headers).Should(Equal([][]string{{"id", "total"}, {"order", "note"}}))
+	})
+
+	It("should write sections", func() {
+		buffer := &bytes.Buffer{}
+		sections := csvencoding.NewSectionWriter(buffer, csvencoding.Dialect{})
+		encoder, err := sections.Section("orders")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(encoder.Encode(order{1, 9.5})).Should(Succeed())
+		encoder, err = sections.Section("items")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(encoder.Encode(item{1, "gift"})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("#SECTION orders\nid,total\n1,9.5\n\n#SECTION items\norder,note\n1,gift\n"))
+
+		// Which reads back
+		reader := csvencoding.NewSectionReader(buffer, csvencoding.Dialect{})
+		name, decoder, err := reader.Next()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("orders"))
+		var o order
+		Ω(decoder.Decode(&o)).Should(Succeed())
+		name, _, err = reader.Next()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("items"))
+	})
+
+	It("should fail sections without a header", func() {
+		input := "#SECTION orders\n#SECTION items\norder,note\n1,gift\n\n#SECTION notes\n"
+		sections := csvencoding.NewSectionReader(strings.NewReader(input), csvencoding.Dialect{})
+		_, _, err := sections.Next()
+		Ω(err).Should(MatchError("section `orders` is empty"))
+
+		name, decoder, err := sections.Next()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(name).Should(Equal("items"))
+		Ω(decoder.Header()).Should(Equal([]string{"order", "note"}))
+
+		_, _, err = sections.Next()
+		Ω(err).Should(MatchError("section `notes` is empty"))
+		_, _, err = sections.Next()
+		Ω(err).Should(Equal(io.EOF))
+	})
+
+	It("should only write single line names", func() {
+		buffer := &bytes.Buffer{}
+		sections := csvencoding.NewSectionWriter(buffer, csvencoding.Dialect{})
+		_, err := sections.Section("orders\nid,total")
+		Ω(err).Should(MatchError(`Can't name a section "orders\nid,total", names are a single line`))
+		Ω(buffer.String()).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,237 @@
+package csvencoding
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The bytes of input Sniff samples
+const sniffSize = 64 * 1024
+
+// Delimiters Sniff chooses from, in order of preference
+var sniffDelimiters = []rune{',', ';', '\t', '|'}
+
+// Sniff infers the Dialect of r from a sample of its start, the
+// delimiter, quote, header, line ending, byte order mark and whether
+// quotes need LazyQuotes, like Python's csv.Sniffer. Part of r is
+// consumed, use SniffReader to decode r afterwards
+func Sniff(r io.Reader) (Dialect, error) {
+	d, _, err := SniffReader(r)
+	return d, err
+}
+
+// SniffReader is Sniff that also returns a reader replaying
+// the sample followed by the rest of r, to decode with the Dialect
+//
+//	d, r, err := csvencoding.SniffReader(upload)
+//	dec := csvencoding.NewReaderDecoder(r, d)
+func SniffReader(r io.Reader) (Dialect, io.Reader, error) {
+	sample := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, sample)
+	sample = sample[:n]
+	replay := io.MultiReader(bytes.NewReader(sample), r)
+
+	complete := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !complete {
+		return Dialect{}, replay, err
+	}
+	d, err := sniff(sample, complete)
+	return d, replay, err
+}
+
+// Infer the Dialect of sample, complete when it's the whole input
+func sniff(sample []byte, complete bool) (Dialect, error) {
+	var d Dialect
+	switch {
+	case bytes.HasPrefix(sample, utf8BOM):
+		d.BOM = true
+	case bytes.HasPrefix(sample, utf16LEBOM):
+		d.BOM, d.Charset = true, UTF16LE
+	case bytes.HasPrefix(sample, utf16BEBOM):
+		d.BOM, d.Charset = true, UTF16BE
+	}
+
+	text, err := io.ReadAll(NewCharsetReader(bytes.NewReader(sample), nil))
+	if err != nil {
+		return d, err
+	}
+	// The last line of a partial sample is likely cut short
+	if !complete {
+		if end := bytes.LastIndexByte(text, '\n'); end >= 0 {
+			text = text[:end+1]
+		}
+	}
+	if len(bytes.TrimSpace(text)) == 0 {
+		return d, fmt.Errorf("Can't sniff the csv dialect of an empty input")
+	}
+
+	d.UseCRLF = bytes.Contains(text, []byte("\r\n"))
+	d.Quote = sniffQuote(text)
+	d.Comma = sniffDelimiter(text, d.Quote)
+
+	// The remaining choices come from parsing the sample,
+	// encoding/csv can't parse other quote characters
+	if d.Quote != '"' {
+		return d, nil
+	}
+	rows, err := sniffRows(text, d.Comma, false)
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && (parseErr.Err == csv.ErrBareQuote || (complete && parseErr.Err == csv.ErrQuote)) {
+		d.LazyQuotes = true
+		rows, _ = sniffRows(text, d.Comma, true)
+	}
+	d.NoHeader = !sniffHeader(rows)
+	d.TrimSpace = sniffSpace(rows)
+	return d, nil
+}
+
+// Quotes open cells, ' is chosen only when " never does
+func sniffQuote(text []byte) rune {
+	opens := func(quote rune) int {
+		count := 0
+		previous := '\n'
+		for _, r := range string(text) {
+			if r == quote && (previous == '\n' || strings.ContainsRune(string(sniffDelimiters), previous)) {
+				count++
+			}
+			if r != ' ' {
+				previous = r
+			}
+		}
+		return count
+	}
+	if opens('"') == 0 && opens('\'') > 0 {
+		return '\''
+	}
+	return '"'
+}
+
+// The delimiter that splits the most records into the same number
+// of cells, ties go to the one making more cells
+func sniffDelimiter(text []byte, quote rune) rune {
+	best, bestConsistency, bestCells := ',', 0.0, 0
+	for _, delimiter := range sniffDelimiters {
+		counts := delimiterCounts(text, delimiter, quote)
+		frequency := make(map[int]int, len(counts))
+		mode := 0
+		for _, count := range counts {
+			frequency[count]++
+			if frequency[count] > frequency[mode] || (frequency[count] == frequency[mode] && count > mode) {
+				mode = count
+			}
+		}
+		if mode == 0 {
+			continue
+		}
+		consistency := float64(frequency[mode]) / float64(len(counts))
+		if consistency > bestConsistency || (consistency == bestConsistency && mode > bestCells) {
+			best, bestConsistency, bestCells = delimiter, consistency, mode
+		}
+	}
+	return best
+}
+
+// The number of delimiters in each record outside of quotes
+func delimiterCounts(text []byte, delimiter, quote rune) []int {
+	var counts []int
+	count, quoted, empty := 0, false, true
+	for _, r := range string(text) {
+		switch {
+		case r == quote:
+			quoted, empty = !quoted, false
+		case quoted:
+		case r == '\n':
+			if !empty {
+				counts = append(counts, count)
+			}
+			count, empty = 0, true
+		case r == delimiter:
+			count, empty = count+1, false
+		case r != '\r':
+			empty = false
+		}
+	}
+	if !empty {
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+// The rows of the sample, those before a parse error are kept,
+// a sample can end inside a quoted cell
+func sniffRows(text []byte, delimiter rune, lazyQuotes bool) ([][]string, error) {
+	r := csv.NewReader(bytes.NewReader(text))
+	r.Comma = delimiter
+	r.LazyQuotes = lazyQuotes
+	r.FieldsPerRecord = -1
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// The first row is a header when its cells don't look like the rest
+// of their column, numbers in a numeric column or the same length as
+// every other cell vote against it. Without any votes it's a header
+func sniffHeader(rows [][]string) bool {
+	if len(rows) < 2 {
+		return true
+	}
+	votes := 0
+	for i, cell := range rows[0] {
+		numeric, sameLength, length, seen := true, true, 0, 0
+		for _, row := range rows[1:] {
+			if i >= len(row) {
+				continue
+			}
+			if !isNumber(row[i]) {
+				numeric = false
+			}
+			if seen == 0 {
+				length = len(row[i])
+			} else if len(row[i]) != length {
+				sameLength = false
+			}
+			seen++
+		}
+		switch {
+		case seen == 0:
+		case numeric && isNumber(cell), !numeric && sameLength && len(cell) == length:
+			votes--
+		case numeric, sameLength:
+			votes++
+		}
+	}
+	return votes >= 0
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
+
+// Every cell after a delimiter starts with a space, "a, b, c"
+func sniffSpace(rows [][]string) bool {
+	spaced := false
+	for _, row := range rows {
+		for _, cell := range row[1:] {
+			if !strings.HasPrefix(cell, " ") {
+				return false
+			}
+			spaced = true
+		}
+	}
+	return spaced
+}
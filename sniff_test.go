@@ -0,0 +1,80 @@
+package csvencoding_test
+
+import (
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sniff", func() {
+	It("should sniff an Excel export", func() {
+		input := "\xEF\xBB\xBFname;price;sku\r\nbob;3,50;A1\r\n\"sue; jr\";4,75;B2\r\n"
+		dialect, err := csvencoding.Sniff(strings.NewReader(input))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(dialect).Should(Equal(csvencoding.Dialect{Comma: ';', Quote: '"', UseCRLF: true, BOM: true}))
+	})
+
+	It("should sniff files without a header", func() {
+		input := "1|2.5|x\n2|3.5|y\n3|4.5|z\n"
+		dialect, err := csvencoding.Sniff(strings.NewReader(input))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(dialect.Comma).Should(Equal('|'))
+		Ω(dialect.NoHeader).Should(BeTrue())
+	})
+
+	It("should sniff when quotes need LazyQuotes", func() {
+		input := "name\tnote\nbob\t6\" tall\nsue\tshort\n"
+		dialect, err := csvencoding.Sniff(strings.NewReader(input))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(dialect.Comma).Should(Equal('\t'))
+		Ω(dialect.LazyQuotes).Should(BeTrue())
+		Ω(dialect.NoHeader).Should(BeFalse())
+	})
+
+	It("should sniff the header of a sample ending inside a quoted cell", func() {
+		input := strings.Repeat("1,2.5,x\n", 100) + "2,3.5,\"" + strings.Repeat("long\nnote ", 10000) + "\"\n"
+		dialect, err := csvencoding.Sniff(strings.NewReader(input))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(dialect.NoHeader).Should(BeTrue())
+	})
+
+	It("should sniff single quotes and spaces after delimiters", func() {
+		dialect, err := csvencoding.Sniff(strings.NewReader("'a,b', 1\n'c,d', 2\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(dialect.Quote).Should(Equal('\''))
+		Ω(dialect.Comma).Should(Equal(','))
+
+		dialect, err = csvencoding.Sniff(strings.NewReader("name, age\nbob, 30\n"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(dialect.TrimSpace).Should(BeTrue())
+	})
+
+	It("should decode what it sniffed", func() {
+		type person struct {
+			Name string
+			Age  int
+		}
+		input := "name;age\n" + strings.Repeat("bob;30\n", 20000)
+		dialect, r, err := csvencoding.SniffReader(strings.NewReader(input))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		decoder := csvencoding.NewReaderDecoder(r, dialect)
+		records := 0
+		for {
+			var output person
+			if decoder.Decode(&output) != nil {
+				break
+			}
+			Ω(output).Should(Equal(person{"bob", 30}))
+			records++
+		}
+		Ω(records).Should(Equal(20000))
+	})
+
+	It("should fail on empty input", func() {
+		_, err := csvencoding.Sniff(strings.NewReader("\n\n"))
+		Ω(err).Should(MatchError("Can't sniff the csv dialect of an empty input"))
+	})
+})
@@ -0,0 +1,504 @@
+package csvencoding
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// A TableSchema is a Frictionless Data Table Schema,
+// see https://specs.frictionlessdata.io/table-schema/
+type TableSchema struct {
+	Fields []TableField `json:"fields"`
+	// Cell values that are read as null, defaults to [""]
+	MissingValues []string `json:"missingValues,omitempty"`
+}
+
+type TableField struct {
+	Name string `json:"name"`
+	// string, number, integer, boolean, date, time, datetime, year,
+	// anything else is read as a string. Integers decode as int64,
+	// or uint64 when too large for it
+	Type        string            `json:"type,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Constraints *FieldConstraints `json:"constraints,omitempty"`
+}
+
+type FieldConstraints struct {
+	Required bool          `json:"required,omitempty"`
+	Unique   bool          `json:"unique,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	// A regular expression the whole cell must match
+	Pattern string `json:"pattern,omitempty"`
+	// Minimum and Maximum are numbers, or strings for dates and times
+	Minimum   interface{} `json:"minimum,omitempty"`
+	Maximum   interface{} `json:"maximum,omitempty"`
+	MinLength *int        `json:"minLength,omitempty"`
+	MaxLength *int        `json:"maxLength,omitempty"`
+}
+
+func (c *FieldConstraints) empty() bool {
+	return !c.Required && !c.Unique && c.Enum == nil && c.Pattern == "" &&
+		c.Minimum == nil && c.Maximum == nil && c.MinLength == nil && c.MaxLength == nil
+}
+
+// LoadTableSchema reads a tableschema.json
+func LoadTableSchema(r io.Reader) (*TableSchema, error) {
+	schema := &TableSchema{}
+	d := json.NewDecoder(r)
+	// Integer constraints past 2^53 are kept exact
+	d.UseNumber()
+	if err := d.Decode(schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// A FieldError is one failed check of one cell
+type FieldError struct {
+	// The data row, 1 is the first record after the header
+	Row int `json:"row"`
+	// The line of the file the row starts on
+	Line  int    `json:"line"`
+	Field string `json:"field"`
+	// type, required, unique, enum, pattern, minimum, maximum,
+	// minLength or maxLength
+	Constraint string `json:"constraint"`
+	Value      string `json:"value"`
+	Message    string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("row %d field `%s`: %s", e.Row, e.Field, e.Message)
+}
+
+// A ValidationError is returned by Decode for a row that doesn't
+// satisfy the TableSchema, the Decoder can carry on with the next row
+type ValidationError struct {
+	Row    int          `json:"row"`
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// A ValidationReport is the outcome of validating a whole file
+type ValidationReport struct {
+	Valid  bool         `json:"valid"`
+	Rows   int          `json:"rows"`
+	Errors []FieldError `json:"errors"`
+}
+
+// Validate checks every remaining row against the TableSchema
+func (dec *Decoder) Validate() (*ValidationReport, error) {
+	return dec.validate(func(row *map[string]interface{}) error {
+		return dec.Decode(row)
+	})
+}
+
+func (dec *Decoder) validate(decode func(*map[string]interface{}) error) (*ValidationReport, error) {
+	if dec.TableSchema == nil {
+		return nil, fmt.Errorf("Validate needs a TableSchema")
+	}
+	report := &ValidationReport{Errors: []FieldError{}}
+	for {
+		row := map[string]interface{}{}
+		err := decode(&row)
+		if err == io.EOF {
+			break
+		}
+		if validationErr, ok := err.(*ValidationError); ok {
+			report.Errors = append(report.Errors, validationErr.Errors...)
+		} else if err != nil {
+			return nil, err
+		}
+		report.Rows++
+	}
+	report.Valid = len(report.Errors) == 0
+	return report, nil
+}
+
+// A TableField compiled against the header
+type fieldValidator struct {
+	field  TableField
+	column int
+	cast   func(string) (interface{}, error)
+	// Constraints cast to the field's type
+	enum     []interface{}
+	pattern  *regexp.Regexp
+	min, max interface{}
+	// Seen values of unique fields
+	seen map[interface{}]bool
+}
+
+func (dec *Decoder) compileTableSchema() ([]*fieldValidator, error) {
+	if dec.validators != nil {
+		return dec.validators, nil
+	}
+	validators := make([]*fieldValidator, len(dec.TableSchema.Fields))
+	for i, field := range dec.TableSchema.Fields {
+		v := &fieldValidator{field: field, column: -1, cast: tableCaster(field)}
+		if columns, ok := dec.columns[normalizeName(field.Name)]; ok {
+			v.column = columns[0]
+		}
+		if c := field.Constraints; c != nil {
+			var err error
+			if c.Pattern != "" {
+				if v.pattern, err = regexp.Compile("^(?:" + c.Pattern + ")$"); err != nil {
+					return nil, fmt.Errorf("field `%s`: %s", field.Name, err.Error())
+				}
+			}
+			for _, value := range c.Enum {
+				typed, err := v.constraintValue(value)
+				if err != nil {
+					return nil, err
+				}
+				v.enum = append(v.enum, typed)
+			}
+			if v.min, err = v.constraintValue(c.Minimum); err != nil {
+				return nil, err
+			}
+			if v.max, err = v.constraintValue(c.Maximum); err != nil {
+				return nil, err
+			}
+			// Bounds only apply to numbers and times of the field's type
+			for i, bound := range []interface{}{v.min, v.max} {
+				if bound != nil && (orderKind(bound) == "" || orderKind(bound) != fieldOrderKind(field.Type)) {
+					constraint := [...]string{"minimum", "maximum"}[i]
+					return nil, fmt.Errorf("field `%s`: Can't check %s `%v` against %s values", field.Name, constraint, bound, field.Type)
+				}
+			}
+			if c.Unique {
+				v.seen = map[interface{}]bool{}
+			}
+		}
+		validators[i] = v
+	}
+	dec.validators = validators
+	return validators, nil
+}
+
+// Constraints come from JSON, numbers as float64 or json.Number
+// and everything else as strings in the field's format
+func (v *fieldValidator) constraintValue(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		if v.field.Type == "integer" || v.field.Type == "year" {
+			if i, err := v.cast(value.String()); err == nil {
+				return i, nil
+			}
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("field `%s`: constraint `%s`: %s", v.field.Name, value, err.Error())
+		}
+		return f, nil
+	case string:
+		typed, err := v.cast(value)
+		if err != nil {
+			return nil, fmt.Errorf("field `%s`: constraint `%s`: %s", v.field.Name, value, err.Error())
+		}
+		return typed, nil
+	case float64:
+		if v.field.Type == "integer" || v.field.Type == "year" {
+			return int64(value), nil
+		}
+		return value, nil
+	}
+	return value, nil
+}
+
+// Decode a record into a map of typed values, checking every constraint
+func (dec *Decoder) validateTo(r []string, m map[string]interface{}) error {
+	validators, err := dec.compileTableSchema()
+	if err != nil {
+		return err
+	}
+
+	missingValues := dec.TableSchema.MissingValues
+	if missingValues == nil {
+		missingValues = []string{""}
+	}
+
+	var errs []FieldError
+	fail := func(v *fieldValidator, constraint, value, message string) {
+		errs = append(errs, FieldError{
+			Row:        dec.row,
+			Line:       dec.line,
+			Field:      v.field.Name,
+			Constraint: constraint,
+			Value:      value,
+			Message:    message,
+		})
+	}
+
+	for _, v := range validators {
+		cell := ""
+		missing := true
+		if v.column >= 0 && v.column < len(r) {
+			cell = r[v.column]
+			missing = false
+			for _, missingValue := range missingValues {
+				if cell == missingValue {
+					missing = true
+				}
+			}
+		}
+
+		c := v.field.Constraints
+		if c == nil {
+			c = &FieldConstraints{}
+		}
+
+		if missing {
+			m[v.field.Name] = nil
+			if c.Required {
+				fail(v, "required", cell, "a value is required")
+			}
+			continue
+		}
+
+		value, err := v.cast(cell)
+		if err != nil {
+			m[v.field.Name] = nil
+			fail(v, "type", cell, fmt.Sprintf("not a valid %s: %s", v.field.Type, err.Error()))
+			continue
+		}
+		m[v.field.Name] = value
+
+		if v.seen != nil {
+			key := value
+			if t, ok := value.(time.Time); ok {
+				key = t.UnixNano()
+			}
+			if v.seen[key] {
+				fail(v, "unique", cell, "the value is repeated")
+			}
+			v.seen[key] = true
+		}
+		if v.enum != nil && !containsValue(v.enum, value) {
+			fail(v, "enum", cell, "the value is not one of the allowed values")
+		}
+		if v.pattern != nil && !v.pattern.MatchString(cell) {
+			fail(v, "pattern", cell, fmt.Sprintf("doesn't match `%s`", c.Pattern))
+		}
+		if v.min != nil && compareValues(value, v.min) < 0 {
+			fail(v, "minimum", cell, fmt.Sprintf("less than %v", c.Minimum))
+		}
+		if v.max != nil && compareValues(value, v.max) > 0 {
+			fail(v, "maximum", cell, fmt.Sprintf("greater than %v", c.Maximum))
+		}
+		if c.MinLength != nil && utf8.RuneCountInString(cell) < *c.MinLength {
+			fail(v, "minLength", cell, fmt.Sprintf("shorter than %d", *c.MinLength))
+		}
+		if c.MaxLength != nil && utf8.RuneCountInString(cell) > *c.MaxLength {
+			fail(v, "maxLength", cell, fmt.Sprintf("longer than %d", *c.MaxLength))
+		}
+	}
+
+	if errs != nil {
+		return &ValidationError{Row: dec.row, Errors: errs}
+	}
+	return nil
+}
+
+// Cast cells to the go type of a Table Schema type
+func tableCaster(field TableField) func(string) (interface{}, error) {
+	switch field.Type {
+	case "integer", "year":
+		return func(s string) (interface{}, error) {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(s, "-") {
+				if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+					return u, nil
+				}
+			}
+			return i, err
+		}
+	case "number":
+		return func(s string) (interface{}, error) {
+			return strconv.ParseFloat(s, 64)
+		}
+	case "boolean":
+		return func(s string) (interface{}, error) {
+			switch s {
+			case "true", "True", "TRUE", "1":
+				return true, nil
+			case "false", "False", "FALSE", "0":
+				return false, nil
+			}
+			return nil, fmt.Errorf("`%s` is not a boolean", s)
+		}
+	case "date":
+		return timeCaster(field.Format, "2006-01-02")
+	case "time":
+		return timeCaster(field.Format, "15:04:05")
+	case "datetime":
+		return timeCaster(field.Format, time.RFC3339)
+	}
+	return func(s string) (interface{}, error) {
+		return s, nil
+	}
+}
+
+func timeCaster(format, layout string) func(string) (interface{}, error) {
+	switch format {
+	case "", "default":
+	case "any":
+		return func(s string) (interface{}, error) {
+			for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "15:04:05"} {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t, nil
+				}
+			}
+			return nil, fmt.Errorf("`%s` is not a recognised time", s)
+		}
+	default:
+		layout = strptime(format)
+	}
+	return func(s string) (interface{}, error) {
+		return time.Parse(layout, s)
+	}
+}
+
+// Go layout from strftime directives, the reverse of strftime
+func strptime(format string) string {
+	pairs := make([]string, 0, len(layoutDirectives))
+	seen := map[string]bool{}
+	for i := 0; i < len(layoutDirectives); i += 2 {
+		directive := layoutDirectives[i+1]
+		if seen[directive] {
+			continue
+		}
+		seen[directive] = true
+		pairs = append(pairs, directive, layoutDirectives[i])
+	}
+	return strings.NewReplacer(pairs...).Replace(format)
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if compareValues(v, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Order two values of the same Table Schema type,
+// values of different types are never equal
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64, uint64, float64:
+		return compareNumbers(a, b)
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case bool:
+		if b, ok := b.(bool); ok && a == b {
+			return 0
+		}
+	}
+	return 2
+}
+
+// What values of a Table Schema type are ordered as,
+// empty when they can't be
+func fieldOrderKind(fieldType string) string {
+	switch fieldType {
+	case "integer", "year", "number":
+		return "number"
+	case "date", "time", "datetime":
+		return "time"
+	}
+	return ""
+}
+
+func orderKind(value interface{}) string {
+	switch value.(type) {
+	case int64, uint64, float64:
+		return "number"
+	case time.Time:
+		return "time"
+	}
+	return ""
+}
+
+// Integers are compared exactly, as floats
+// those past 2^53 would be rounded
+func compareNumbers(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return cmp.Compare(a, b)
+		case uint64:
+			if a < 0 {
+				return -1
+			}
+			return cmp.Compare(uint64(a), b)
+		}
+	case uint64:
+		switch b := b.(type) {
+		case uint64:
+			return cmp.Compare(a, b)
+		case int64:
+			return -compareNumbers(b, a)
+		}
+	}
+	x, ok := toFloat(a)
+	y, ok2 := toFloat(b)
+	if !ok || !ok2 {
+		return 2
+	}
+	return compareFloats(x, y)
+}
+
+func toFloat(n interface{}) (float64, bool) {
+	switch n := n.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+package csvencoding_test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Table Schema validation", func() {
+	tableSchema := `{
+		"fields": [
+			{"name": "id", "type": "integer", "constraints": {"required": true, "unique": true, "minimum": 1}},
+			{"name": "email", "type": "string", "constraints": {"pattern": "[^@]+@[^@]+"}},
+			{"name": "plan", "type": "string", "constraints": {"enum": ["free", "pro"]}},
+			{"name": "joined", "type": "date", "format": "%d/%m/%Y", "constraints": {"maximum": "31/12/2017"}},
+			{"name": "active", "type": "boolean"}
+		],
+		"missingValues": ["", "NULL"]
+	}`
+
+	newDecoder := func(input string) *csvencoding.Decoder {
+		schema, err := csvencoding.LoadTableSchema(strings.NewReader(tableSchema))
+		Ω(err).Should(BeNil())
+		decoder := csvencoding.NewDecoder(reader(input))
+		decoder.TableSchema = schema
+		return decoder
+	}
+
+	It("should decode typed values", func() {
+		decoder := newDecoder("id,email,plan,joined,active\n1,vin@example.com,pro,15/05/2017,true\n")
+		output := map[string]interface{}{}
+		err := decoder.Decode(&output)
+		Ω(err).Should(BeNil())
+		Ω(output).Should(Equal(map[string]interface{}{
+			"id":     int64(1),
+			"email":  "vin@example.com",
+			"plan":   "pro",
+			"joined": time.Date(2017, 5, 15, 0, 0, 0, 0, time.UTC),
+			"active": true,
+		}))
+	})
+
+	It("should report failed constraints and carry on", func() {
+		decoder := newDecoder("id,email,plan,joined,active\n" +
+			"0,vin,gold,01/01/2018,yes\n" +
+			"NULL,dom@example.com,free,NULL,false\n" +
+			"2,a@b.c,free,NULL,false\n" +
+			"2,a@b.c,free,NULL,false\n")
+
+		output := map[string]interface{}{}
+		err := decoder.Decode(&output)
+		Ω(err).Should(BeAssignableToTypeOf(&csvencoding.ValidationError{}))
+		constraints := []string{}
+		for _, fieldErr := range err.(*csvencoding.ValidationError).Errors {
+			Ω(fieldErr.Row).Should(Equal(1))
+			Ω(fieldErr.Line).Should(Equal(2))
+			constraints = append(constraints, fieldErr.Constraint)
+		}
+		Ω(constraints).Should(Equal([]string{"minimum", "pattern", "enum", "maximum", "type"}))
+
+		err = decoder.Decode(&output)
+		Ω(err).Should(MatchError(ContainSubstring("a value is required")))
+		Ω(output["joined"]).Should(BeNil())
+
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		err = decoder.Decode(&output)
+		Ω(err.(*csvencoding.ValidationError).Errors[0].Constraint).Should(Equal("unique"))
+
+		Ω(decoder.Decode(&output)).Should(Equal(io.EOF))
+	})
+
+	It("should produce a machine readable report", func() {
+		decoder := newDecoder("id,email,plan,joined,active\n1,a@b.c,free,,true\n2,a@b.c,team,,true\n")
+		report, err := decoder.Validate()
+		Ω(err).Should(BeNil())
+		b, err := json.Marshal(report)
+		Ω(err).Should(BeNil())
+		Ω(b).Should(MatchJSON(`{
+			"valid": false,
+			"rows": 2,
+			"errors": [{
+				"row": 2, "line": 3, "field": "plan", "constraint": "enum",
+				"value": "team", "message": "the value is not one of the allowed values"
+			}]
+		}`))
+	})
+
+	It("should compare large integers exactly", func() {
+		schema, err := csvencoding.LoadTableSchema(strings.NewReader(`{"fields": [
+			{"name": "id", "type": "integer", "constraints": {"unique": true, "maximum": 9223372036854775806}},
+			{"name": "count", "type": "integer", "constraints": {"minimum": 0, "maximum": 18446744073709551615}}
+		]}`))
+		Ω(err).Should(BeNil())
+		decoder := csvencoding.NewDecoder(reader("id,count\n" +
+			"9007199254740993,18446744073709551615\n" +
+			"9007199254740992,1\n" +
+			"9223372036854775807,18446744073709551616\n"))
+		decoder.TableSchema = schema
+		report, err := decoder.Validate()
+		Ω(err).Should(BeNil())
+		Ω(report.Errors).Should(HaveLen(2))
+		Ω(report.Errors[0].Constraint).Should(Equal("maximum"))
+		Ω(report.Errors[0].Value).Should(Equal("9223372036854775807"))
+		Ω(report.Errors[1].Constraint).Should(Equal("type"))
+	})
+
+	It("should reject bounds a field's values can't be ordered against", func() {
+		for _, field := range []string{
+			`{"name": "name", "type": "string", "constraints": {"maximum": 5}}`,
+			`{"name": "active", "type": "boolean", "constraints": {"minimum": 1}}`,
+			`{"name": "joined", "type": "date", "constraints": {"minimum": 5}}`,
+		} {
+			schema, err := csvencoding.LoadTableSchema(strings.NewReader(`{"fields": [` + field + `]}`))
+			Ω(err).Should(BeNil())
+			decoder := csvencoding.NewDecoder(reader("name,active,joined\nhello,true,2017-01-01\n"))
+			decoder.TableSchema = schema
+			_, err = decoder.Validate()
+			Ω(err).Should(HaveOccurred())
+		}
+
+		schema, err := csvencoding.LoadTableSchema(strings.NewReader(`{"fields": [
+			{"name": "name", "type": "string", "constraints": {"maximum": 5}}
+		]}`))
+		Ω(err).Should(BeNil())
+		decoder := csvencoding.NewDecoder(reader("name\nhello\n"))
+		decoder.TableSchema = schema
+		row := map[string]interface{}{}
+		Ω(decoder.Decode(&row)).Should(MatchError("field `name`: Can't check maximum `5` against string values"))
+	})
+
+	It("should only decode into maps", func() {
+		decoder := newDecoder("id\n1\n")
+		output := struct{ ID int }{}
+		Ω(decoder.Decode(&output)).Should(HaveOccurred())
+	})
+})
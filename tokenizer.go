@@ -0,0 +1,385 @@
+package csvencoding
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The records a Decoder reads, a csv.Reader or a tokenizer
+type recordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+}
+
+// A tokenizer is the in-package counterpart of csv.Reader used by
+// NewReaderDecoder, with the same quoting rules and ParseErrors,
+// that enforces the Decoder's Limits while reading. The cells of the
+// record read are views of a buffer the next read reuses, structs
+// parse them in place, Read copies them
+type tokenizer struct {
+	r                *bufio.Reader
+	comma            rune
+	comment          rune
+	quote            []byte
+	lazyQuotes       bool
+	trimLeadingSpace bool
+	// Set by the first record, like csv.Reader
+	fieldsPerRecord int
+	// The Decoder's Limits, enforced while reading
+	limits *Limits
+
+	// The bytes read of the record so far
+	recordBytes int
+
+	// The line last read
+	numLine int
+	// Lines longer than the bufio.Reader's buffer
+	rawBuffer []byte
+	// The unquoted cells of the record, back to back
+	recordBuffer   []byte
+	fieldIndexes   []int
+	fieldPositions []position
+}
+
+type position struct {
+	line, col int
+}
+
+var errInvalidDelim = errors.New("csv: invalid field or comment delimiter")
+
+func newTokenizer(r io.Reader) *tokenizer {
+	return &tokenizer{r: bufio.NewReader(r), comma: ',', quote: []byte{'"'}}
+}
+
+func validDelim(r rune) bool {
+	return r != 0 && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// Read the next record, like csv.Reader the cells share one allocation
+func (t *tokenizer) Read() ([]string, error) {
+	ok, err := t.read()
+	if !ok {
+		return nil, err
+	}
+	buffer := string(t.recordBuffer)
+	record := make([]string, len(t.fieldIndexes))
+	for i := range record {
+		start, end := t.bounds(i)
+		record[i] = buffer[start:end]
+	}
+	return record, err
+}
+
+// The number of cells of the record read
+func (t *tokenizer) fields() int {
+	return len(t.fieldIndexes)
+}
+
+// A cell of the record read, valid until the next read
+func (t *tokenizer) cell(i int) []byte {
+	start, end := t.bounds(i)
+	return t.recordBuffer[start:end:end]
+}
+
+// Where cell i starts and ends in the buffer
+func (t *tokenizer) bounds(i int) (start, end int) {
+	if i > 0 {
+		start = t.fieldIndexes[i-1]
+	}
+	return start, t.fieldIndexes[i]
+}
+
+// Read the next record into the buffer, ok is false when there is
+// none. Like csv.Reader a record may come with a ParseError
+func (t *tokenizer) read() (ok bool, err error) {
+	quote, _ := utf8.DecodeRune(t.quote)
+	if t.comma == t.comment || t.comma == quote || !validDelim(t.comma) || !validDelim(quote) ||
+		(t.comment != 0 && !validDelim(t.comment)) {
+		return false, errInvalidDelim
+	}
+
+	// Read a line, skipping past empty lines and comments
+	var line []byte
+	var errRead error
+	for errRead == nil {
+		t.recordBytes = 0
+		line, errRead = t.readLine()
+		if t.comment != 0 && nextRune(line) == t.comment {
+			line = nil
+			continue
+		}
+		if errRead == nil && len(line) == lengthNL(line) {
+			line = nil
+			continue
+		}
+		break
+	}
+	if errRead == io.EOF {
+		return false, errRead
+	}
+	if _, ok := errRead.(*LimitError); ok {
+		return false, &RecordError{Line: t.numLine + 1, Err: errRead}
+	}
+
+	quoteLen := len(t.quote)
+	commaLen := utf8.RuneLen(t.comma)
+	recLine := t.numLine
+	t.recordBuffer = t.recordBuffer[:0]
+	t.fieldIndexes = t.fieldIndexes[:0]
+	t.fieldPositions = t.fieldPositions[:0]
+	pos := position{line: t.numLine, col: 1}
+parseField:
+	for {
+		if t.trimLeadingSpace {
+			i := bytes.IndexFunc(line, func(r rune) bool {
+				return !unicode.IsSpace(r)
+			})
+			if i < 0 {
+				i = len(line)
+				pos.col -= lengthNL(line)
+			}
+			line = line[i:]
+			pos.col += i
+		}
+		if !t.quoted(line) {
+			// An unquoted cell
+			i, j := t.scan(line)
+			field := line
+			if i >= 0 {
+				field = field[:i]
+			} else {
+				field = field[:len(field)-lengthNL(field)]
+			}
+			if !t.lazyQuotes {
+				if j >= 0 {
+					err = &csv.ParseError{StartLine: recLine, Line: t.numLine, Column: pos.col + j, Err: csv.ErrBareQuote}
+					break parseField
+				}
+			}
+			t.recordBuffer = append(t.recordBuffer, field...)
+			if err = t.endField(pos); err != nil {
+				break parseField
+			}
+			if i >= 0 {
+				line = line[i+commaLen:]
+				pos.col += i + commaLen
+				continue parseField
+			}
+			break parseField
+		}
+
+		// A quoted cell
+		fieldPos := pos
+		line = line[quoteLen:]
+		pos.col += quoteLen
+		for {
+			i := bytes.Index(line, t.quote)
+			switch {
+			case i >= 0:
+				t.recordBuffer = append(t.recordBuffer, line[:i]...)
+				line = line[i+quoteLen:]
+				pos.col += i + quoteLen
+				switch rn := nextRune(line); {
+				case rn == quote:
+					// A doubled quote
+					t.recordBuffer = append(t.recordBuffer, t.quote...)
+					line = line[quoteLen:]
+					pos.col += quoteLen
+				case rn == t.comma:
+					// The end of the cell
+					line = line[commaLen:]
+					pos.col += commaLen
+					if err = t.endField(fieldPos); err != nil {
+						break parseField
+					}
+					continue parseField
+				case lengthNL(line) == len(line):
+					// The end of the record
+					err = t.endField(fieldPos)
+					break parseField
+				case t.lazyQuotes:
+					t.recordBuffer = append(t.recordBuffer, t.quote...)
+				default:
+					err = &csv.ParseError{StartLine: recLine, Line: t.numLine, Column: pos.col - quoteLen, Err: csv.ErrQuote}
+					break parseField
+				}
+			case len(line) > 0:
+				// A line break inside the quotes
+				t.recordBuffer = append(t.recordBuffer, line...)
+				if err = t.checkCell(); err != nil {
+					break parseField
+				}
+				if errRead != nil {
+					break parseField
+				}
+				pos.col += len(line)
+				line, errRead = t.readLine()
+				if len(line) > 0 {
+					pos.line++
+					pos.col = 1
+				}
+				if errRead == io.EOF {
+					errRead = nil
+				}
+				if _, ok := errRead.(*LimitError); ok {
+					err = errRead
+					break parseField
+				}
+			default:
+				// The input ends inside the quotes
+				if !t.lazyQuotes && errRead == nil {
+					err = &csv.ParseError{StartLine: recLine, Line: pos.line, Column: pos.col, Err: csv.ErrQuote}
+					break parseField
+				}
+				err = t.endField(fieldPos)
+				break parseField
+			}
+		}
+	}
+	if _, ok := err.(*LimitError); ok {
+		return false, &RecordError{Line: recLine, Err: err}
+	}
+	if err == nil {
+		err = errRead
+	}
+
+	if t.fieldsPerRecord > 0 {
+		if len(t.fieldIndexes) != t.fieldsPerRecord && err == nil {
+			err = &csv.ParseError{StartLine: recLine, Line: recLine, Column: 1, Err: csv.ErrFieldCount}
+		}
+	} else if t.fieldsPerRecord == 0 {
+		t.fieldsPerRecord = len(t.fieldIndexes)
+	}
+	return true, err
+}
+
+// End the cell at the end of recordBuffer, checking the Limits
+func (t *tokenizer) endField(pos position) error {
+	if err := t.checkCell(); err != nil {
+		return err
+	}
+	t.fieldIndexes = append(t.fieldIndexes, len(t.recordBuffer))
+	t.fieldPositions = append(t.fieldPositions, pos)
+	if t.limits != nil && exceeds(len(t.fieldIndexes), t.limits.MaxFields) {
+		return &LimitError{"MaxFields", t.limits.MaxFields}
+	}
+	return nil
+}
+
+// Is the cell being read over MaxCellBytes
+func (t *tokenizer) checkCell() error {
+	if t.limits == nil || t.limits.MaxCellBytes == 0 {
+		return nil
+	}
+	start := 0
+	if n := len(t.fieldIndexes); n > 0 {
+		start = t.fieldIndexes[n-1]
+	}
+	if exceeds(len(t.recordBuffer)-start, t.limits.MaxCellBytes) {
+		return &LimitError{"MaxCellBytes", t.limits.MaxCellBytes}
+	}
+	return nil
+}
+
+// Does the line start with a quote
+func (t *tokenizer) quoted(line []byte) bool {
+	if len(t.quote) == 1 {
+		return len(line) > 0 && line[0] == t.quote[0]
+	}
+	return bytes.HasPrefix(line, t.quote)
+}
+
+// The indexes of the first delimiter and quote of an unquoted cell,
+// quotes after the delimiter aren't looked for. Cells are short so
+// for single byte delimiters a loop beats two calls to bytes.Index
+func (t *tokenizer) scan(line []byte) (comma, quote int) {
+	if t.comma >= utf8.RuneSelf || len(t.quote) != 1 {
+		comma = bytes.IndexRune(line, t.comma)
+		field := line
+		if comma >= 0 {
+			field = line[:comma]
+		}
+		return comma, bytes.Index(field, t.quote)
+	}
+	c, q := byte(t.comma), t.quote[0]
+	quote = -1
+	for i, b := range line {
+		switch b {
+		case c:
+			return i, quote
+		case q:
+			if quote < 0 {
+				quote = i
+			}
+		}
+	}
+	return -1, quote
+}
+
+// The line and column a cell of the last record started at
+func (t *tokenizer) FieldPos(field int) (line, column int) {
+	if field < 0 || field >= len(t.fieldPositions) {
+		panic("out of range index passed to FieldPos")
+	}
+	p := t.fieldPositions[field]
+	return p.line, p.col
+}
+
+// Read a line, \r\n is normalized to \n. Lines are buffered
+// no further than the record's MaxRecordBytes
+func (t *tokenizer) readLine() ([]byte, error) {
+	line, err := t.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		t.rawBuffer = append(t.rawBuffer[:0], line...)
+		for err == bufio.ErrBufferFull {
+			if err := t.count(len(t.rawBuffer)); err != nil {
+				return nil, err
+			}
+			line, err = t.r.ReadSlice('\n')
+			t.rawBuffer = append(t.rawBuffer, line...)
+		}
+		line = t.rawBuffer
+	}
+	if err := t.count(len(line)); err != nil {
+		return nil, err
+	}
+	t.recordBytes += len(line)
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+		// Like csv.Reader a trailing \r before EOF is dropped
+		if line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+	}
+	t.numLine++
+	if n := len(line); n >= 2 && line[n-2] == '\r' && line[n-1] == '\n' {
+		line[n-2] = '\n'
+		line = line[:n-1]
+	}
+	return line, err
+}
+
+// Would n more bytes take the record over MaxRecordBytes
+func (t *tokenizer) count(n int) error {
+	if t.limits != nil && exceeds(t.recordBytes+n, t.limits.MaxRecordBytes) {
+		return &LimitError{"MaxRecordBytes", t.limits.MaxRecordBytes}
+	}
+	return nil
+}
+
+func lengthNL(b []byte) int {
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		return 1
+	}
+	return 0
+}
+
+func nextRune(b []byte) rune {
+	r, _ := utf8.DecodeRune(b)
+	return r
+}
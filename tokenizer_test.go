@@ -0,0 +1,272 @@
+package csvencoding_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tokenizer", func() {
+	// Read every record of input both ways
+	readAll := func(input string, d csvencoding.Dialect) ([][]string, error, [][]string, error) {
+		reader := csv.NewReader(strings.NewReader(input))
+		if d.Comma != 0 {
+			reader.Comma = d.Comma
+		}
+		reader.Comment = d.Comment
+		reader.LazyQuotes = d.LazyQuotes
+		reader.TrimLeadingSpace = d.TrimSpace
+		expected, expectedErr := reader.ReadAll()
+
+		d.NoHeader = true
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), d)
+		var records [][]string
+		for {
+			var record []string
+			err := decoder.Decode(&record)
+			if err == io.EOF {
+				return expected, expectedErr, records, nil
+			}
+			if err != nil {
+				return expected, expectedErr, records, err
+			}
+			records = append(records, record)
+		}
+	}
+
+	inputs := map[string]string{
+		"plain":            "a,b,c\n1,2,3\n",
+		"crlf":             "a,b\r\n1,2\r\n",
+		"no trailing line": "a,b\n1,2",
+		"trailing cr":      "a,b\n1,2\r",
+		"empty lines":      "a,b\n\n\n1,2\n",
+		"empty cells":      ",,\n,,\n",
+		"quoted":           "\"a,b\",\"say \"\"hi\"\"\"\n\"\",x\n",
+		"quoted newline":   "\"two\nlines\",b\n\"cr\r\nlf\",d\n",
+		"bare quote":       "a,b\"c\n",
+		"extraneous quote": "a,\"b\"c\n",
+		"unterminated":     "a,b\n\"c,d\n",
+		"field count":      "a,b\n1,2,3\n",
+		"multibyte":        "é,ü\n\"ß\",日本\n",
+		"long line":        strings.Repeat("x", 10000) + ",y\n",
+	}
+	for name, input := range inputs {
+		input := input
+		It("should read like csv.Reader: "+name, func() {
+			expected, expectedErr, records, err := readAll(input, csvencoding.Dialect{})
+			if expectedErr != nil {
+				Ω(err).Should(Equal(expectedErr))
+				return
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(records).Should(Equal(expected))
+		})
+	}
+
+	It("should read like csv.Reader with a dialect", func() {
+		input := "# comment\na; \"b;c\"\n  1;2\n"
+		dialect := csvencoding.Dialect{Comma: ';', Comment: '#', TrimSpace: true}
+		expected, expectedErr, records, err := readAll(input, dialect)
+		Ω(expectedErr).ShouldNot(HaveOccurred())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal(expected))
+
+		input = "a,b\"c\n\"d\"e\",f\n"
+		expected, expectedErr, records, err = readAll(input, csvencoding.Dialect{LazyQuotes: true})
+		Ω(expectedErr).ShouldNot(HaveOccurred())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal(expected))
+	})
+
+	It("should report where a bad quote is", func() {
+		input := "a,b\n1,\"2\n3\"x,4\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var output []string
+		err := decoder.Decode(&output)
+		Ω(err).Should(Equal(&csv.ParseError{StartLine: 2, Line: 3, Column: 2, Err: csv.ErrQuote}))
+	})
+
+	It("should read a custom quote", func() {
+		type person struct {
+			Name string
+			Age  int
+		}
+		input := "name,age\n'o''brien, pat',30\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{Quote: '\''})
+		var output person
+		Ω(decoder.Decode(&output)).Should(Succeed())
+		Ω(output).Should(Equal(person{"o'brien, pat", 30}))
+	})
+
+	It("should keep cells past the next read", func() {
+		input := "name,city\nbob,paris\nsue,rome\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var first, second map[string]string
+		var row []string
+		Ω(decoder.Decode(&first)).Should(Succeed())
+		Ω(decoder.Decode(&second)).Should(Succeed())
+		Ω(decoder.Decode(&row)).Should(Equal(io.EOF))
+		Ω(first).Should(Equal(map[string]string{"name": "bob", "city": "paris"}))
+		Ω(second).Should(Equal(map[string]string{"name": "sue", "city": "rome"}))
+		Ω(decoder.Header()).Should(Equal([]string{"name", "city"}))
+	})
+
+	It("should parse struct fields from views like from csv.Reader", func() {
+		type measure struct {
+			Name  string
+			Count *int
+			Ratio float32
+			Ok    bool
+			Size  uint8
+		}
+		input := "name,count,ratio,ok,size\n bob , 3 ,0.5,true,7\nsue,NULL,,false\nkim,x,1,true,1\n"
+		decodeAll := func(decoder *csvencoding.Decoder) ([]measure, error) {
+			decoder.TrimSpace = true
+			decoder.NilValue = "NULL"
+			decoder.Ragged = csvencoding.RaggedPadEmpty
+			var output []measure
+			for {
+				var m measure
+				if err := decoder.Decode(&m); err != nil {
+					return output, err
+				}
+				output = append(output, m)
+			}
+		}
+		expected, expectedErr := decodeAll(csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input))))
+		output, err := decodeAll(csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{}))
+		Ω(output).Should(Equal(expected))
+		Ω(output).Should(HaveLen(2))
+		Ω(*output[0].Count).Should(Equal(3))
+		Ω(output[1].Count).Should(BeNil())
+		Ω(err).Should(Equal(expectedErr))
+		Ω(err).Should(MatchError(`line 4: strconv.ParseInt: parsing "x": invalid syntax`))
+	})
+
+	It("should keep struct strings past the next read", func() {
+		type visit struct {
+			Name string
+			City *string
+			Tags []string
+		}
+		input := "name,city,tags\nbob,paris,a\nsue,rome,b\n"
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var output []visit
+		for {
+			var v visit
+			if decoder.Decode(&v) != nil {
+				break
+			}
+			output = append(output, v)
+		}
+		Ω(output).Should(HaveLen(2))
+		Ω(output[0].Name).Should(Equal("bob"))
+		Ω(*output[0].City).Should(Equal("paris"))
+		Ω(output[0].Tags).Should(Equal([]string{"a"}))
+	})
+})
+
+// A record of many columns of each kind
+type wideRecord struct {
+	A1, A2, A3, A4, A5, A6 int
+	B1, B2, B3, B4, B5, B6 float64
+	C1, C2, C3, C4, C5, C6 string
+	D1, D2, D3, D4, D5, D6 bool
+}
+
+func wideInput(b *testing.B) string {
+	var input strings.Builder
+	var header []string
+	for _, prefix := range []string{"a", "b", "c", "d"} {
+		for i := 1; i <= 6; i++ {
+			header = append(header, fmt.Sprintf("%s%d", prefix, i))
+		}
+	}
+	input.WriteString(strings.Join(header, ",") + "\n")
+	for row := 0; row < 5000; row++ {
+		var cells []string
+		for i := 0; i < 6; i++ {
+			cells = append(cells, fmt.Sprint(row*i))
+		}
+		for i := 0; i < 6; i++ {
+			cells = append(cells, fmt.Sprintf("%d.25", row+i))
+		}
+		for i := 0; i < 6; i++ {
+			cells = append(cells, fmt.Sprintf("\"name, %d\"", row+i))
+		}
+		for i := 0; i < 6; i++ {
+			cells = append(cells, "true")
+		}
+		input.WriteString(strings.Join(cells, ",") + "\n")
+	}
+	b.SetBytes(int64(input.Len()))
+	b.ResetTimer()
+	return input.String()
+}
+
+func BenchmarkWideCSVReader(b *testing.B) {
+	input := wideInput(b)
+	for i := 0; i < b.N; i++ {
+		decoder := csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input)))
+		var output wideRecord
+		for decoder.Decode(&output) == nil {
+		}
+	}
+}
+
+func BenchmarkWideReaderDecoder(b *testing.B) {
+	input := wideInput(b)
+	for i := 0; i < b.N; i++ {
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var output wideRecord
+		for decoder.Decode(&output) == nil {
+		}
+	}
+}
+
+// A record the tokenizer's views are parsed into without any copy
+type numericRecord struct {
+	A1, A2, A3, A4, A5, A6 int
+	B1, B2, B3, B4, B5, B6 uint32
+	D1, D2, D3, D4, D5, D6 bool
+}
+
+func numericInput(b *testing.B) string {
+	var input strings.Builder
+	input.WriteString("a1,a2,a3,a4,a5,a6,b1,b2,b3,b4,b5,b6,d1,d2,d3,d4,d5,d6\n")
+	for row := 0; row < 5000; row++ {
+		for i := 0; i < 12; i++ {
+			fmt.Fprintf(&input, "%d,", row*i)
+		}
+		input.WriteString("true,false,true,false,true,false\n")
+	}
+	b.SetBytes(int64(input.Len()))
+	b.ResetTimer()
+	return input.String()
+}
+
+func BenchmarkNumericCSVReader(b *testing.B) {
+	input := numericInput(b)
+	for i := 0; i < b.N; i++ {
+		decoder := csvencoding.NewDecoder(csv.NewReader(strings.NewReader(input)))
+		var output numericRecord
+		for decoder.Decode(&output) == nil {
+		}
+	}
+}
+
+func BenchmarkNumericReaderDecoder(b *testing.B) {
+	input := numericInput(b)
+	for i := 0; i < b.N; i++ {
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		var output numericRecord
+		for decoder.Decode(&output) == nil {
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package csvencoding
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A struct type rows are decoded into, or encoded from,
+// when the discriminator column holds value
+type variant struct {
+	column string
+	value  string
+	// The struct type and whether it was registered as a pointer
+	typ     reflect.Type
+	pointer bool
+}
+
+func newVariant(column, value string, v interface{}) variant {
+	t := reflect.TypeOf(v)
+	pointer := t != nil && t.Kind() == reflect.Ptr
+	if pointer {
+		t = t.Elem()
+	}
+	return variant{column: column, value: value, typ: t, pointer: pointer}
+}
+
+// RegisterVariant decodes records whose column holds value into the
+// type of v, a struct or struct pointer, when decoding into a pointer
+// to an interface. The type must implement the interface, ie
+// dec.RegisterVariant("type", "click", ClickEvent{})
+func (dec *Decoder) RegisterVariant(column, value string, v interface{}) {
+	dec.variants = append(dec.variants, newVariant(column, value, v))
+}
+
+// Decode a record into the interface v, as the variant
+// chosen by its discriminator column
+func (dec *Decoder) decodeVariant(r []string, v reflect.Value, line int) error {
+	if len(dec.variants) == 0 {
+		return fmt.Errorf("Can't unmarshal csv into %s without a registered variant", v.Type())
+	}
+	var discriminators []string
+	for _, variant := range dec.variants {
+		columns, ok := dec.columns[normalizeName(variant.column)]
+		if !ok || columns[0] >= len(r) {
+			continue
+		}
+		cell := r[columns[0]]
+		if cell != variant.value {
+			discriminators = append(discriminators, variant.column+"="+cell)
+			continue
+		}
+		if variant.typ == nil || variant.typ.Kind() != reflect.Struct {
+			return fmt.Errorf("Can't unmarshal csv into variant %v, use a struct", variant.typ)
+		}
+
+		value := reflect.New(variant.typ)
+		if err := dec.decodeRecord(r, value.Elem(), line); err != nil {
+			return err
+		}
+		if !variant.pointer {
+			value = value.Elem()
+		}
+		if !value.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("Can't assign variant %s to %s", value.Type(), v.Type())
+		}
+		v.Set(value)
+		return nil
+	}
+	if len(discriminators) == 0 {
+		return fmt.Errorf("the header has none of the variant columns")
+	}
+	return fmt.Errorf("no variant is registered for %s", discriminators[0])
+}
+
+// RegisterVariant encodes values of the type of v with column set to
+// value. Every registered type is written under one union header, the
+// discriminator column followed by the columns of each type in turn,
+// the cells of other variants' columns are left empty
+func (enc *Encoder) RegisterVariant(column, value string, v interface{}) {
+	enc.variants = append(enc.variants, newVariant(column, value, v))
+	enc.union = nil
+}
+
+// The columns written for every registered variant
+type union struct {
+	header []string
+	// type -> the union position of each of its columns
+	positions map[reflect.Type][]int
+}
+
+// The registered variant of t, if any
+func (enc *Encoder) variantOf(t reflect.Type) (variant, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for _, variant := range enc.variants {
+		if variant.typ == t {
+			return variant, true
+		}
+	}
+	return variant{}, false
+}
+
+func (enc *Encoder) unionLayout() (*union, error) {
+	if enc.union != nil {
+		return enc.union, nil
+	}
+	u := &union{positions: map[reflect.Type][]int{}}
+	columns := map[string]int{}
+	add := func(name string) int {
+		key := normalizeName(name)
+		if position, ok := columns[key]; ok {
+			return position
+		}
+		columns[key] = len(u.header)
+		u.header = append(u.header, name)
+		return columns[key]
+	}
+	for _, variant := range enc.variants {
+		add(variant.column)
+	}
+	for _, variant := range enc.variants {
+		if _, ok := u.positions[variant.typ]; ok {
+			continue
+		}
+		l, err := enc.layoutFor(variant.typ)
+		if err != nil {
+			return nil, err
+		}
+		positions := make([]int, len(l.header))
+		for i, name := range l.header {
+			positions[i] = add(name)
+		}
+		u.positions[variant.typ] = positions
+	}
+	enc.union = u
+	return u, nil
+}
+
+// Spread the cells of a variant across the union header,
+// the columns of other variants are fill
+func spread[T any](u *union, v variant, cells []T, fill T) []T {
+	spread := make([]T, len(u.header))
+	for i := range spread {
+		spread[i] = fill
+	}
+	for i, position := range u.positions[v.typ] {
+		if i < len(cells) {
+			spread[position] = cells[i]
+		}
+	}
+	return spread
+}
+
+// The record of a variant under the union header
+func (enc *Encoder) variantRecord(record []string, v variant) ([]string, error) {
+	u, err := enc.unionLayout()
+	if err != nil {
+		return nil, err
+	}
+	record = spread(u, v, record, enc.EmptyValue)
+	for i, name := range u.header {
+		if normalizeName(name) == normalizeName(v.column) {
+			record[i] = v.value
+		}
+	}
+	return record, nil
+}
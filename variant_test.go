@@ -0,0 +1,117 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type event interface {
+	user() string
+}
+
+type clickEvent struct {
+	User   string
+	Button string
+}
+
+func (e clickEvent) user() string { return e.User }
+
+type viewEvent struct {
+	User     string
+	Page     string
+	Duration int
+}
+
+func (e *viewEvent) user() string { return e.User }
+
+var _ = Describe("Variants", func() {
+	input := "type,user,button,page,duration\nclick,bob,left,,\nview,sue,,/home,30\n"
+
+	It("should decode the variant chosen by a column", func() {
+		decoder := csvencoding.NewReaderDecoder(strings.NewReader(input), csvencoding.Dialect{})
+		decoder.RegisterVariant("type", "click", clickEvent{})
+		decoder.RegisterVariant("type", "view", &viewEvent{})
+		var events []event
+		for {
+			var e event
+			err := decoder.Decode(&e)
+			if err == io.EOF {
+				break
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			events = append(events, e)
+		}
+		Ω(events).Should(Equal([]event{
+			clickEvent{"bob", "left"},
+			&viewEvent{"sue", "/home", 30},
+		}))
+	})
+
+	It("should decode variants in parallel", func() {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+		var b strings.Builder
+		b.WriteString("type,user,button,page,duration\n")
+		for i := 0; i < 8*64; i++ {
+			if (i/64)%2 == 0 {
+				fmt.Fprintf(&b, "click,u%d,left,,\n", i)
+			} else {
+				fmt.Fprintf(&b, "view,u%d,,/home,%d\n", i, i)
+			}
+		}
+
+		decoder := csvencoding.NewDecoder(reader(b.String()))
+		decoder.RegisterVariant("type", "click", clickEvent{})
+		decoder.RegisterVariant("type", "view", &viewEvent{})
+		parallel := csvencoding.NewParallelDecoder[event](decoder, 8)
+		defer parallel.Close()
+		for i := 0; ; i++ {
+			var e event
+			err := parallel.Decode(&e)
+			if err == io.EOF {
+				Ω(i).Should(Equal(8 * 64))
+				break
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(e.user()).Should(Equal(fmt.Sprintf("u%d", i)))
+		}
+	})
+
+	It("should fail unknown variants", func() {
+		decoder := csvencoding.NewDecoder(reader("type,user\nscroll,bob\n"))
+		decoder.RegisterVariant("type", "click", clickEvent{})
+		var e event
+		Ω(decoder.Decode(&e)).Should(MatchError("line 2: no variant is registered for type=scroll"))
+
+		decoder = csvencoding.NewDecoder(reader("type,user\nclick,bob\n"))
+		Ω(decoder.Decode(&e)).Should(MatchError("Can't unmarshal csv into *csvencoding_test.event"))
+	})
+
+	It("should encode variants under a union header", func() {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.Dialect{})
+		encoder.RegisterVariant("type", "click", clickEvent{})
+		encoder.RegisterVariant("type", "view", &viewEvent{})
+		Ω(encoder.Encode(clickEvent{"bob", "left"})).Should(Succeed())
+		Ω(encoder.Encode(&viewEvent{"sue", "/home", 30})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal(input))
+	})
+
+	It("should project the union header chosen by Columns between encodes", func() {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, csvencoding.Dialect{})
+		encoder.RegisterVariant("type", "click", clickEvent{})
+		encoder.RegisterVariant("type", "view", &viewEvent{})
+		Ω(encoder.Encode(clickEvent{"bob", "left"})).Should(Succeed())
+		encoder.Columns([]string{"user=who"})
+		Ω(encoder.Header(&viewEvent{})).Should(Equal([]string{"type", "who"}))
+		Ω(encoder.Encode(&viewEvent{"sue", "/home", 30})).Should(Succeed())
+		Ω(buffer.String()).Should(Equal("type,user,button,page,duration\nclick,bob,left,,\nview,sue\n"))
+	})
+})
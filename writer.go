@@ -0,0 +1,218 @@
+package csvencoding
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A QuotePolicy decides which cells an Encoder quotes
+type QuotePolicy int
+
+const (
+	// Quote cells containing the delimiter, a quote or a line break,
+	// or starting with a space, like csv.Writer
+	QuoteMinimal QuotePolicy = iota
+	// Quote every cell
+	QuoteAlways
+	// Quote every cell except those of numeric fields, by their go kind
+	QuoteNonNumeric
+	// Never quote, the delimiter, quote, escape and line breaks
+	// in cells are preceded by the Encoder's Escape instead
+	QuoteNone
+)
+
+// The records an Encoder writes, either a csv.Writer
+// or for NewWriterEncoder the quoting aware writer
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// A writer is csv.Writer with quoting policies
+type writer struct {
+	w       *bufio.Writer
+	comma   rune
+	quote   rune
+	useCRLF bool
+	err     error
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{w: bufio.NewWriter(w), comma: ',', quote: '"'}
+}
+
+// Write a record with minimal quoting
+func (w *writer) Write(record []string) error {
+	return w.writeRecord(record, QuoteMinimal, 0, nil)
+}
+
+// Write a record, numeric marks the cells of numeric fields,
+// escape replaces doubling of quotes when it isn't 0
+func (w *writer) writeRecord(record []string, policy QuotePolicy, escape rune, numeric []bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	// Fail before any of the record is written
+	if policy == QuoteNone && escape == 0 {
+		for _, cell := range record {
+			if w.needsEscape(cell, escape) {
+				return fmt.Errorf("cell `%s` needs quoting, set an Escape to write it unquoted", cell)
+			}
+		}
+	}
+	for i, cell := range record {
+		if i > 0 {
+			w.w.WriteRune(w.comma)
+		}
+
+		quote := w.needsQuotes(cell)
+		switch policy {
+		case QuoteAlways:
+			quote = true
+		case QuoteNonNumeric:
+			quote = quote || i >= len(numeric) || !numeric[i]
+		case QuoteNone:
+			if !w.needsEscape(cell, escape) {
+				w.w.WriteString(cell)
+				continue
+			}
+			for _, r := range cell {
+				if r == w.comma || r == w.quote || r == escape || r == '\r' || r == '\n' {
+					w.w.WriteRune(escape)
+				}
+				w.w.WriteRune(r)
+			}
+			continue
+		}
+		if !quote {
+			w.w.WriteString(cell)
+			continue
+		}
+
+		w.w.WriteRune(w.quote)
+		for _, r := range cell {
+			switch {
+			case r == w.quote && escape != 0, r == escape:
+				w.w.WriteRune(escape)
+				w.w.WriteRune(r)
+			case r == w.quote:
+				w.w.WriteRune(r)
+				w.w.WriteRune(r)
+			case r == '\r':
+				if !w.useCRLF {
+					w.w.WriteRune(r)
+				}
+			case r == '\n' && w.useCRLF:
+				w.w.WriteString("\r\n")
+			default:
+				w.w.WriteRune(r)
+			}
+		}
+		w.w.WriteRune(w.quote)
+	}
+
+	var err error
+	if w.useCRLF {
+		_, err = w.w.WriteString("\r\n")
+	} else {
+		err = w.w.WriteByte('\n')
+	}
+	return err
+}
+
+// The same rule as csv.Writer
+func (w *writer) needsQuotes(cell string) bool {
+	if cell == "" {
+		return false
+	}
+	if cell == `\.` {
+		return true
+	}
+	if strings.ContainsRune(cell, w.comma) || strings.ContainsRune(cell, w.quote) || strings.ContainsAny(cell, "\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(cell)
+	return unicode.IsSpace(r)
+}
+
+// Unquoted cells can't contain the delimiter, quotes or line breaks
+func (w *writer) needsEscape(cell string, escape rune) bool {
+	return strings.ContainsRune(cell, w.comma) || strings.ContainsRune(cell, w.quote) ||
+		(escape != 0 && strings.ContainsRune(cell, escape)) || strings.ContainsAny(cell, "\r\n")
+}
+
+func (w *writer) Flush() {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+}
+
+func (w *writer) Error() error {
+	return w.err
+}
+
+// Which cells of the record written for v are numeric
+func (enc *Encoder) numericCells(v reflect.Value) ([]bool, error) {
+	switch {
+	case v.Type() == stringsType:
+		return nil, nil
+	case isRow(v):
+		keys, _, _, err := enc.rowColumns(v)
+		if err != nil {
+			return nil, err
+		}
+		v = reflect.Indirect(v)
+		numeric := make([]bool, len(keys))
+		for i, key := range keys {
+			value := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+			if value.Kind() == reflect.Interface && !value.IsNil() {
+				value = value.Elem()
+			}
+			numeric[i] = value.IsValid() && isNumeric(value.Type())
+		}
+		return numeric, nil
+	}
+
+	l, err := enc.layoutFor(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	numeric := make([]bool, len(l.header))
+	for i := range numeric {
+		source := i
+		if l.sources != nil {
+			source = l.sources[i]
+		}
+		numeric[i] = source >= 0 && isNumeric(l.columns[source].typ)
+	}
+	if variant, ok := enc.variantOf(v.Type()); ok {
+		u, err := enc.unionLayout()
+		if err != nil {
+			return nil, err
+		}
+		return spread(u, variant, numeric, false), nil
+	}
+	return numeric, nil
+}
+
+// Numbers are written as is unless the type marshals itself
+func isNumeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+	ptr := reflect.PtrTo(t)
+	return !ptr.Implements(getterType) && !ptr.Implements(textMarshalerType)
+}
@@ -0,0 +1,101 @@
+package csvencoding_test
+
+import (
+	"bytes"
+	"encoding/csv"
+
+	"github.com/hcliff/csvencoding"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// An int written as text
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte([]string{"low", "high"}[l]), nil
+}
+
+var _ = Describe("Quoting", func() {
+	type reading struct {
+		Sensor string
+		Value  float64
+		Count  *int
+		Ok     bool
+		Level  level
+	}
+	input := reading{"a-1", 2.5, nil, true, 1}
+
+	encode := func(dialect csvencoding.Dialect, configure func(*csvencoding.Encoder), records ...interface{}) (string, error) {
+		buffer := &bytes.Buffer{}
+		encoder := csvencoding.NewWriterEncoder(buffer, dialect)
+		configure(encoder)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return buffer.String(), err
+			}
+		}
+		return buffer.String(), nil
+	}
+
+	It("should quote minimally like csv.Writer", func() {
+		cells := []string{"", "plain", "a,b", `say "hi"`, "two\nlines", " lead", `\.`, "cr\r\n"}
+		buffer := &bytes.Buffer{}
+		w := csv.NewWriter(buffer)
+		w.UseCRLF = true
+		w.Write(cells)
+		w.Flush()
+
+		output, err := encode(csvencoding.RFC4180, func(*csvencoding.Encoder) {}, cells)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal(buffer.String()))
+	})
+
+	It("should quote every cell", func() {
+		output, err := encode(csvencoding.Dialect{}, func(e *csvencoding.Encoder) {
+			e.Quoting = csvencoding.QuoteAlways
+		}, input)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal(`"sensor","value","count","ok","level"` + "\n" + `"a-1","2.5","NULL","true","high"` + "\n"))
+	})
+
+	It("should quote cells of non-numeric fields", func() {
+		output, err := encode(csvencoding.Dialect{}, func(e *csvencoding.Encoder) {
+			e.Quoting = csvencoding.QuoteNonNumeric
+		}, input, map[string]interface{}{"id": 7, "name": "bob"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal(`"sensor","value","count","ok","level"` + "\n" +
+			`"a-1",2.5,NULL,"true","high"` + "\n" +
+			`7,"bob"` + "\n"))
+	})
+
+	It("should escape rather than quote", func() {
+		cells := []string{"a,b", `say "hi"`, `c:\dir`}
+		output, err := encode(csvencoding.Dialect{NoHeader: true}, func(e *csvencoding.Encoder) {
+			e.Quoting = csvencoding.QuoteNone
+			e.Escape = '\\'
+		}, cells)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal(`a\,b,say \"hi\",c:\\dir` + "\n"))
+
+		_, err = encode(csvencoding.Dialect{NoHeader: true}, func(e *csvencoding.Encoder) {
+			e.Quoting = csvencoding.QuoteNone
+		}, cells)
+		Ω(err).Should(MatchError("cell `a,b` needs quoting, set an Escape to write it unquoted"))
+	})
+
+	It("should escape quotes in quoted cells", func() {
+		output, err := encode(csvencoding.Dialect{NoHeader: true, Quote: '\''}, func(e *csvencoding.Encoder) {
+			e.Quoting = csvencoding.QuoteAlways
+			e.Escape = '\\'
+		}, []string{"it's", `a\b`})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(output).Should(Equal(`'it\'s','a\\b'` + "\n"))
+	})
+
+	It("should only quote minimally with a csv.Writer", func() {
+		encoder := csvencoding.NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+		encoder.Quoting = csvencoding.QuoteAlways
+		Ω(encoder.Encode(input)).Should(MatchError("Can't apply quoting policies to a csv.Writer, use NewWriterEncoder"))
+	})
+})